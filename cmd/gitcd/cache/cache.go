@@ -23,6 +23,9 @@ import (
   "os"
   "errors"
   "fmt"
+  "path"
+  "sort"
+  "strings"
 )
 
 // The cache stores the usages of certain repositories in order to find repositories by a shorter name.
@@ -32,7 +35,8 @@ import (
  * The YAML structure for the cache file.
  *
  * `apiVersion` is current 1.
- * `nameMap` maps from repo name to list of owners, in order of last access.
+ * `nameMap` maps from repo name to list of owners, in order of last access. Owners on hosts other than the
+ * repository.DefaultHost are prefixed with their host.
  *
  * Example:
  *
@@ -42,41 +46,104 @@ import (
  *   - coollog
  *   bar:
  *   - foo
- *   - cat
+ *   - gitlab.com/cat
  */
 type RepoCache struct {
   ApiVersion int
   NameMap    map[string][]string
 }
 
-/** Bumps the repo to the top. */
+/**
+ * Bumps the repo to the top.
+ *
+ * Hosts like GitHub ignore case, so owners are the same if their repositories have the same repository.Key, and owners
+ * under names that differ only in case are merged into the name of repoToBump if their hosts ignore case. Owners on
+ * hosts that do not ignore case keep their own name.
+ */
 func (r *RepoCache) Bump(repoToBump repository.Repository) {
+  ownerToBump := path.Dir(repoToBump.Path())
+
   // Starts a new list with the repos.
   var newOwnerList []string
-  newOwnerList = append(newOwnerList, repoToBump.Owner)
+  newOwnerList = append(newOwnerList, ownerToBump)
 
   // Collects current repos to map.
-  if owners, ok := r.NameMap[repoToBump.Name]; ok {
-    ownerMap := make(map[string]bool)
-    ownerMap[repoToBump.Owner] = true
-
-    for _, owner := range owners {
-      if _, ok := ownerMap[owner]; !ok {
+  ownerMap := make(map[string]bool)
+  ownerMap[repoToBump.Key()] = true
+
+  for _, name := range r.findNames(repoToBump.Name) {
+    var keptOwners []string
+    for _, owner := range r.NameMap[name] {
+      if name != repoToBump.Name && !ignoresCase(owner, name) {
+        keptOwners = append(keptOwners, owner)
+        continue
+      }
+      ownerKey := repositoryKey(owner, name)
+      if _, ok := ownerMap[ownerKey]; !ok {
         newOwnerList = append(newOwnerList, owner)
-        ownerMap[owner] = true
+        ownerMap[ownerKey] = true
       }
     }
+    if len(keptOwners) > 0 {
+      r.NameMap[name] = keptOwners
+    } else {
+      delete(r.NameMap, name)
+    }
   }
 
   r.NameMap[repoToBump.Name] = newOwnerList
 }
 
-/** Gets the list of owners to try for the repoName, in the order in which to try them. */
+/**
+ * Gets the list of owners to try for the repoName, in the order in which to try them. Each owner joined with the
+ * repoName is a path for repository.FromPath. The repoName matches names that differ only in case.
+ */
 func (r *RepoCache) FindOwners(repoName string) []string {
-  if owners, ok := r.NameMap[repoName]; ok {
-    return owners
+  owners := []string{}
+  ownerMap := make(map[string]bool)
+  for _, name := range r.findNames(repoName) {
+    for _, owner := range r.NameMap[name] {
+      ownerKey := repositoryKey(owner, name)
+      if _, ok := ownerMap[ownerKey]; !ok {
+        owners = append(owners, owner)
+        ownerMap[ownerKey] = true
+      }
+    }
+  }
+  return owners
+}
+
+/** Finds the names in the NameMap that match repoName ignoring case. The exact repoName is first. */
+func (r *RepoCache) findNames(repoName string) []string {
+  var names []string
+  if _, ok := r.NameMap[repoName]; ok {
+    names = append(names, repoName)
+  }
+
+  var otherNames []string
+  for name := range r.NameMap {
+    if name != repoName && strings.EqualFold(name, repoName) {
+      otherNames = append(otherNames, name)
+    }
+  }
+  sort.Strings(otherNames)
+
+  return append(names, otherNames...)
+}
+
+/** Checks if the host of the repo at owner/name ignores case. */
+func ignoresCase(owner string, name string) bool {
+  repo, err := repository.FromPath(path.Join(owner, name))
+  return err == nil && repository.FindForge(repo.Host).CaseInsensitive
+}
+
+/** Gets the repository.Key for the repo at owner/name. */
+func repositoryKey(owner string, name string) string {
+  repo, err := repository.FromPath(path.Join(owner, name))
+  if err != nil {
+    return path.Join(owner, name)
   }
-  return []string{}
+  return repo.Key()
 }
 
 /** Loads the gitcdFile into the RepoCache structure. */
@@ -36,10 +36,52 @@ func TestBump(t *testing.T) {
   repoCache.testBump(`foo`, `bar`, []string{`foo`, `foo2`}, t)
   repoCache.testBump(`fake`, `gitcd`, []string{`fake`, `coollog`, `imposter`}, t)
   repoCache.testBump(`coollog`, `gitcd`, []string{`coollog`, `fake`, `imposter`}, t)
+  repoCache.testBumpHost(`gitlab.com`, `coollog`, `gitcd`, []string{`gitlab.com/coollog`, `coollog`, `fake`, `imposter`}, t)
+  repoCache.testBumpHost(`github.com`, `fake`, `gitcd`, []string{`fake`, `gitlab.com/coollog`, `coollog`, `imposter`}, t)
+  repoCache.testBumpHost(`gitlab.com`, `platform/infra`, `terraform-modules`, []string{`gitlab.com/platform/infra`}, t)
+}
+
+func TestBumpIgnoresCase(t *testing.T) {
+  repoCache := RepoCache {
+    ApiVersion: 1,
+    NameMap: map[string][]string{
+      `GitCD`: {`CoolLog`, `imposter`},
+      `gitcd`: {`coollog`, `other`},
+      `tool`: {`git.example.com/team`},
+    },
+  }
+
+  repoCache.testBump(`coollog`, `gitcd`, []string{`coollog`, `other`, `imposter`}, t)
+  if _, ok := repoCache.NameMap[`GitCD`]; ok {
+    t.Errorf("Bump did not merge names that differ only in case: %#v", repoCache.NameMap)
+  }
+
+  // Owners on case-sensitive hosts stay separate.
+  repoCache.testBumpHost(`git.example.com`, `Team`, `tool`, []string{`git.example.com/Team`, `git.example.com/team`}, t)
+
+  expectedOwners := []string{`coollog`, `other`, `imposter`}
+  if owners := repoCache.FindOwners(`GITCD`); !reflect.DeepEqual(owners, expectedOwners) {
+    t.Errorf("FindOwners expected `%#v`, but got `%#v`", expectedOwners, owners)
+  }
+
+  // Names that differ only in case stay separate for owners on case-sensitive hosts.
+  repoCache.NameMap[`Lib`] = []string{`git.example.com/team`}
+  repoCache.testBump(`coollog`, `lib`, []string{`coollog`}, t)
+  if owners := repoCache.NameMap[`Lib`]; !reflect.DeepEqual(owners, []string{`git.example.com/team`}) {
+    t.Errorf("Bump should not have merged owners on case-sensitive hosts, but got `%#v`", repoCache.NameMap)
+  }
+  if owners := repoCache.FindOwners(`Lib`); !reflect.DeepEqual(owners, []string{`git.example.com/team`, `coollog`}) {
+    t.Errorf("FindOwners expected the case-sensitive owner first, but got `%#v`", owners)
+  }
 }
 
 func (repoCache *RepoCache) testBump(owner string, name string, expectedOwnerList []string, t *testing.T) {
+  repoCache.testBumpHost(``, owner, name, expectedOwnerList, t)
+}
+
+func (repoCache *RepoCache) testBumpHost(host string, owner string, name string, expectedOwnerList []string, t *testing.T) {
   repoToBump := repository.Repository{
+    Host: host,
     Owner: owner,
     Name: name,
   }
@@ -0,0 +1,330 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package config
+
+import (
+  "io/ioutil"
+  "gopkg.in/yaml.v2"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+  "github.com/coollog/gitcd/cmd/gitcd/registry"
+  "github.com/coollog/gitcd/cmd/gitcd/hook"
+  "os"
+  "errors"
+  "fmt"
+  "strings"
+  "path"
+)
+
+// The config is written by the user to customize how gitcd parses and clones repositories.
+
+/**
+ * The YAML structure for the config file.
+ *
+ * `apiVersion` is currently 1.
+ * `rewrites` rewrites URLs like git's `url.<base>.insteadOf` and `url.<base>.pushInsteadOf`. These apply in addition
+ * to the rewrites in the git config.
+ *
+ * Example:
+ *
+ * apiVersion: 1
+ * rewrites:
+ * - base: https://mirror.example.com/github/
+ *   insteadOf: https://github.com/
+ * - base: 'git@github.com:'
+ *   pushInsteadOf: https://github.com/
+ * prefixes:
+ *   work:
+ *     url: 'git@git.example.com:'
+ *     owner: platform
+ * localPaths:
+ *   /srv/git: git.example.com
+ * hosts:
+ *   git.example.org: forgejo
+ * registries:
+ *   npm: https://npm.mirror.example.com
+ * goImportHosts:
+ * - go.example.com
+ * cloneUrls:
+ *   github.com:
+ *   - 'git@github.com:{owner}/{name}.git'
+ *   - https://github.com/{owner}/{name}
+ * cloneDefaults:
+ * - match: org/monorepo
+ *   filter: blob:none
+ *   mirror: true
+ * mirrorDirectory: ~/.gitcd-mirrors
+ * fork:
+ *   user: myuser
+ * hooks:
+ * - match: org/*
+ *   postClone: [make bootstrap]
+ */
+type Config struct {
+  ApiVersion int       `yaml:"apiVersion"`
+  Rewrites   []Rewrite `yaml:"rewrites"`
+  // Maps from prefix names to what they stand for, like `work` in `work:billing`. See repository.Prefix.
+  Prefixes map[string]Prefix `yaml:"prefixes"`
+  // Maps from local paths to the repository prefixes of the repositories under them. See repository.Parser.
+  LocalPaths map[string]string `yaml:"localPaths"`
+  // Maps from self-hosted hosts to the names of their forges, like `forgejo`. See repository.ForgeNames.
+  Hosts map[string]string `yaml:"hosts"`
+  // Maps from registry names, like `npm`, to the base URLs of their registries. See registry.DefaultBaseUrls.
+  Registries map[string]string `yaml:"registries"`
+  // Hosts of Go import paths to resolve with `go-import` meta tags, like `go.example.com`. See goimport.DefaultImportHosts.
+  GoImportHosts []string `yaml:"goImportHosts"`
+  // Maps from hosts (or `*` for any host) to the templates of URLs to clone with, in order. See repository.CloneUrlTemplates.
+  CloneUrls map[string][]string `yaml:"cloneUrls"`
+  // How to clone repositories, like shallow or blobless. Later matching entries override the options that they set, but
+  // cannot unset options that earlier entries set, like `depth` back to 0 or `sparse` back to false.
+  CloneDefaults []CloneDefault `yaml:"cloneDefaults"`
+  // The directory of the mirrors that clones borrow objects from. Defaults to `~/.gitcd-mirrors`.
+  MirrorDirectory string `yaml:"mirrorDirectory"`
+  // How `--fork` finds the fork of a repository.
+  Fork Fork `yaml:"fork"`
+  // Commands to run in repositories. All matching entries run, in order.
+  Hooks []Hook `yaml:"hooks"`
+}
+
+type Rewrite struct {
+  Base          string `yaml:"base"`
+  InsteadOf     string `yaml:"insteadOf"`
+  PushInsteadOf string `yaml:"pushInsteadOf"`
+}
+
+/** How to clone the repositories that match the glob pattern. See matches for what the pattern matches. */
+type CloneDefault struct {
+  Match       string   `yaml:"match"`
+  Depth       int      `yaml:"depth"`
+  Filter      string   `yaml:"filter"`
+  Sparse      bool     `yaml:"sparse"`
+  SparsePaths []string `yaml:"sparsePaths"`
+  // Whether to keep a mirror of the repositories that clones borrow objects from.
+  Mirror bool `yaml:"mirror"`
+  // Whether to initialize submodules recursively, how many to fetch at once, and whether to make them shallow.
+  Submodules        bool `yaml:"submodules"`
+  SubmoduleJobs     int  `yaml:"submoduleJobs"`
+  ShallowSubmodules bool `yaml:"shallowSubmodules"`
+}
+
+type Fork struct {
+  // The user whose fork to clone.
+  User string `yaml:"user"`
+  // Maps from hosts to the user on that host, if it is not the User.
+  Users map[string]string `yaml:"users"`
+  // Where the clone of the fork goes: `upstream` for the directory of the repository it is forked from (the default),
+  // or `fork` for the directory of the fork.
+  Location string `yaml:"location"`
+}
+
+/** The values of Fork.Location. */
+const (
+  ForkLocationUpstream = `upstream`
+  ForkLocationFork     = `fork`
+)
+
+/** Commands to run in the repositories that match the glob pattern, like `org/*`. See matches for the pattern. */
+type Hook struct {
+  Match string `yaml:"match"`
+  // Commands to run after cloning.
+  PostClone []string `yaml:"postClone"`
+  // Commands to run each time gitcd goes to the repository.
+  OnEnter []string `yaml:"onEnter"`
+}
+
+type Prefix struct {
+  Url   string `yaml:"url"`
+  Owner string `yaml:"owner"`
+}
+
+/** Converts the rewrites into repository.Rewrites. */
+func (c *Config) RepositoryRewrites() repository.Rewrites {
+  var rewrites repository.Rewrites
+  for _, rewrite := range c.Rewrites {
+    if len(rewrite.InsteadOf) > 0 {
+      rewrites = append(rewrites, repository.Rewrite{Base: rewrite.Base, InsteadOf: rewrite.InsteadOf})
+    }
+    if len(rewrite.PushInsteadOf) > 0 {
+      rewrites = append(rewrites, repository.Rewrite{Base: rewrite.Base, InsteadOf: rewrite.PushInsteadOf, Push: true})
+    }
+  }
+  return rewrites
+}
+
+/** Converts the prefixes into repository.Prefix values. */
+func (c *Config) RepositoryPrefixes() map[string]repository.Prefix {
+  prefixes := make(map[string]repository.Prefix)
+  for name, prefix := range c.Prefixes {
+    prefixes[name] = repository.Prefix{Url: prefix.Url, Owner: prefix.Owner}
+  }
+  return prefixes
+}
+
+/** Converts the hosts into the rules of their forges. */
+func (c *Config) RepositoryForges() map[string]repository.Forge {
+  forges := make(map[string]repository.Forge)
+  for host, forgeName := range c.Hosts {
+    forge := repository.ForgeNames[strings.ToLower(forgeName)]
+    // Self-hosted hosts identify their own repositories, unlike `ssh.dev.azure.com` for `dev.azure.com`.
+    forge.Host = ``
+    forges[strings.ToLower(host)] = forge
+  }
+  return forges
+}
+
+/** Converts the cloneUrls into repository.CloneUrlTemplates. */
+func (c *Config) RepositoryCloneUrlTemplates() repository.CloneUrlTemplates {
+  templates := make(repository.CloneUrlTemplates)
+  for host, hostTemplates := range c.CloneUrls {
+    templates[strings.ToLower(host)] = hostTemplates
+  }
+  return templates
+}
+
+/** Gets the clone options for the repo from the cloneDefaults that match it. */
+func (c *Config) RepositoryCloneOptions(repo repository.Repository) repository.CloneOptions {
+  var options repository.CloneOptions
+  for _, cloneDefault := range c.CloneDefaults {
+    if !matches(cloneDefault.Match, repo) {
+      continue
+    }
+    if cloneDefault.Depth > 0 {
+      options.Depth = cloneDefault.Depth
+    }
+    if len(cloneDefault.Filter) > 0 {
+      options.Filter = cloneDefault.Filter
+    }
+    if cloneDefault.Sparse {
+      options.Sparse, options.SparsePaths = true, cloneDefault.SparsePaths
+    }
+    if cloneDefault.Submodules {
+      options.Submodules = true
+    }
+    if cloneDefault.SubmoduleJobs > 0 {
+      options.SubmoduleJobs = cloneDefault.SubmoduleJobs
+    }
+    if cloneDefault.ShallowSubmodules {
+      options.ShallowSubmodules = true
+    }
+  }
+  return options
+}
+
+/** Checks if the repo should borrow objects from a mirror, according to the cloneDefaults that match it. */
+func (c *Config) UsesMirror(repo repository.Repository) bool {
+  usesMirror := false
+  for _, cloneDefault := range c.CloneDefaults {
+    if matches(cloneDefault.Match, repo) && cloneDefault.Mirror {
+      usesMirror = true
+    }
+  }
+  return usesMirror
+}
+
+/** Gets the hooks for the repo from all the hooks that match it. */
+func (c *Config) RepositoryHooks(repo repository.Repository) hook.Hooks {
+  var hooks hook.Hooks
+  for _, configHook := range c.Hooks {
+    if matches(configHook.Match, repo) {
+      hooks.PostClone = append(hooks.PostClone, configHook.PostClone...)
+      hooks.OnEnter = append(hooks.OnEnter, configHook.OnEnter...)
+    }
+  }
+  return hooks
+}
+
+/** Gets the fork of the upstream owned by the user from the fork config. */
+func (c *Config) ForkRepository(upstream repository.Repository) (repository.Repository, error) {
+  host := upstream.HostOrDefault()
+  user, ok := c.Fork.Users[host]
+  if !ok {
+    user = c.Fork.User
+  }
+  if len(user) == 0 {
+    return repository.Repository{}, errors.New(fmt.Sprintf("No fork user for `%s` in the config; set `fork.user` or `fork.users`", host))
+  }
+  return repository.ForkOf(upstream, user), nil
+}
+
+/**
+ * Checks if the glob pattern matches the repo: its host (like `gitlab.example.com`), the repository with its host (like
+ * `github.com/org/*`), or the directory of the repository (like `org/monorepo`).
+ */
+func matches(pattern string, repo repository.Repository) bool {
+  host := repo.HostOrDefault()
+  for _, name := range []string{host, path.Join(host, repo.Owner, repo.Name), repo.Path()} {
+    if matched, _ := path.Match(pattern, name); matched {
+      return true
+    }
+  }
+  return false
+}
+
+/** Loads the configFile into the Config structure. A missing configFile is an empty Config. */
+func Load(configFile string) (Config, error) {
+  if _, err := os.Stat(configFile); os.IsNotExist(err) {
+    return Config{ApiVersion: 1}, nil
+  }
+
+  configFileContents, err := ioutil.ReadFile(configFile)
+  if err != nil {
+    return Config{}, err
+  }
+
+  config := Config{}
+
+  err = yaml.UnmarshalStrict(configFileContents, &config)
+  if err != nil {
+    return Config{}, errors.New(fmt.Sprintf("Config file at `%s` is not valid: %s", configFile, err.Error()))
+  }
+
+  if config.ApiVersion != 1 {
+    return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has unknown apiVersion: %d", configFile, config.ApiVersion))
+  }
+
+  for host, forgeName := range config.Hosts {
+    if _, ok := repository.ForgeNames[strings.ToLower(forgeName)]; !ok {
+      return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has unknown forge `%s` for host `%s`", configFile, forgeName, host))
+    }
+  }
+  for registryName := range config.Registries {
+    if _, ok := registry.DefaultBaseUrls[registryName]; !ok {
+      return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has unknown registry `%s`", configFile, registryName))
+    }
+  }
+  for host, templates := range config.CloneUrls {
+    for _, template := range templates {
+      if !strings.Contains(template, `{name}`) && !strings.Contains(template, `{path}`) {
+        return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has clone URL `%s` for host `%s` without `{name}` or `{path}`", configFile, template, host))
+      }
+    }
+  }
+  if location := config.Fork.Location; len(location) > 0 && location != ForkLocationUpstream && location != ForkLocationFork {
+    return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has unknown fork location `%s`", configFile, location))
+  }
+  for _, cloneDefault := range config.CloneDefaults {
+    if _, err := path.Match(cloneDefault.Match, ``); err != nil || len(cloneDefault.Match) == 0 {
+      return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has clone default with invalid match `%s`", configFile, cloneDefault.Match))
+    }
+  }
+  for _, configHook := range config.Hooks {
+    if _, err := path.Match(configHook.Match, ``); err != nil || len(configHook.Match) == 0 {
+      return Config{}, errors.New(fmt.Sprintf("Config file at `%s` has hook with invalid match `%s`", configFile, configHook.Match))
+    }
+  }
+
+  return config, nil
+}
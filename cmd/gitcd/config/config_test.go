@@ -0,0 +1,236 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package config
+
+import (
+  "testing"
+  "io/ioutil"
+  "os"
+  "path"
+  "reflect"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+  "github.com/coollog/gitcd/cmd/gitcd/hook"
+)
+
+func TestLoad(t *testing.T) {
+  configFile := writeConfig(`
+apiVersion: 1
+rewrites:
+- base: https://mirror.example.com/github/
+  insteadOf: https://github.com/
+- base: 'git@github.com:'
+  pushInsteadOf: https://github.com/
+prefixes:
+  work:
+    url: 'git@git.corp.example.com:'
+    owner: platform
+localPaths:
+  /srv/git: git.corp.example.com
+hosts:
+  Git.Example.org: forgejo
+registries:
+  npm: http://localhost:4873
+goImportHosts:
+- go.example.com
+cloneUrls:
+  GitHub.com:
+  - 'git@{host}:{owner}/{name}.git'
+  - https://{host}/{path}
+cloneDefaults:
+- match: gitlab.example.com
+  depth: 1
+  submodules: true
+  submoduleJobs: 8
+- match: org/monorepo
+  filter: blob:none
+  mirror: true
+  sparse: true
+  sparsePaths: [services/api]
+- match: gitlab.example.com/big/*
+  depth: 10
+fork:
+  user: myuser
+  users:
+    gitlab.com: otheruser
+  location: fork
+hooks:
+- match: org/*
+  postClone: [make bootstrap]
+- match: github.com/org/monorepo
+  postClone: [npm ci]
+  onEnter: [git fetch]
+`, t)
+  defer os.RemoveAll(path.Dir(configFile))
+
+  config, err := Load(configFile)
+  if err != nil {
+    t.Fatalf("Load errored: %s", err.Error())
+  }
+
+  expectedRewrites := repository.Rewrites{
+    {Base: `https://mirror.example.com/github/`, InsteadOf: `https://github.com/`},
+    {Base: `git@github.com:`, InsteadOf: `https://github.com/`, Push: true},
+  }
+  if rewrites := config.RepositoryRewrites(); !reflect.DeepEqual(rewrites, expectedRewrites) {
+    t.Errorf("Rewrites expected `%#v` but got `%#v`", expectedRewrites, rewrites)
+  }
+
+  expectedPrefixes := map[string]repository.Prefix{
+    `work`: {Url: `git@git.corp.example.com:`, Owner: `platform`},
+  }
+  if prefixes := config.RepositoryPrefixes(); !reflect.DeepEqual(prefixes, expectedPrefixes) {
+    t.Errorf("Prefixes expected `%#v` but got `%#v`", expectedPrefixes, prefixes)
+  }
+
+  expectedLocalPaths := map[string]string{`/srv/git`: `git.corp.example.com`}
+  if !reflect.DeepEqual(config.LocalPaths, expectedLocalPaths) {
+    t.Errorf("LocalPaths expected `%#v` but got `%#v`", expectedLocalPaths, config.LocalPaths)
+  }
+
+  forges := config.RepositoryForges()
+  if forge, ok := forges[`git.example.org`]; !ok || !forge.CaseInsensitive || forge.Nested {
+    t.Errorf("Forges expected Forgejo rules for `git.example.org` but got `%#v`", forges)
+  }
+
+  expectedRegistries := map[string]string{`npm`: `http://localhost:4873`}
+  if !reflect.DeepEqual(config.Registries, expectedRegistries) {
+    t.Errorf("Registries expected `%#v` but got `%#v`", expectedRegistries, config.Registries)
+  }
+
+  if expectedGoImportHosts := []string{`go.example.com`}; !reflect.DeepEqual(config.GoImportHosts, expectedGoImportHosts) {
+    t.Errorf("GoImportHosts expected `%#v` but got `%#v`", expectedGoImportHosts, config.GoImportHosts)
+  }
+
+  expectedTemplates := repository.CloneUrlTemplates{`github.com`: {`git@{host}:{owner}/{name}.git`, `https://{host}/{path}`}}
+  if templates := config.RepositoryCloneUrlTemplates(); !reflect.DeepEqual(templates, expectedTemplates) {
+    t.Errorf("CloneUrlTemplates expected `%#v` but got `%#v`", expectedTemplates, templates)
+  }
+
+  expectedCloneOptions := []struct {
+    repository repository.Repository
+    expectedOptions repository.CloneOptions
+  }{
+    {repository.Repository{Host: `github.com`, Owner: `org`, Name: `monorepo`}, repository.CloneOptions{Filter: `blob:none`, Sparse: true, SparsePaths: []string{`services/api`}}},
+    {repository.Repository{Host: `gitlab.example.com`, Owner: `team`, Name: `tool`}, repository.CloneOptions{Depth: 1, Submodules: true, SubmoduleJobs: 8}},
+    {repository.Repository{Host: `gitlab.example.com`, Owner: `big`, Name: `tool`}, repository.CloneOptions{Depth: 10, Submodules: true, SubmoduleJobs: 8}},
+    {repository.Repository{Host: `github.com`, Owner: `org`, Name: `other`}, repository.CloneOptions{}},
+  }
+  for _, expectedCloneOption := range expectedCloneOptions {
+    if options := config.RepositoryCloneOptions(expectedCloneOption.repository); !reflect.DeepEqual(options, expectedCloneOption.expectedOptions) {
+      t.Errorf("CloneOptions for `%#v` expected `%#v` but got `%#v`", expectedCloneOption.repository, expectedCloneOption.expectedOptions, options)
+    }
+  }
+
+  if !config.UsesMirror(repository.Repository{Host: `github.com`, Owner: `org`, Name: `monorepo`}) {
+    t.Errorf("`org/monorepo` should use a mirror")
+  }
+  if config.UsesMirror(repository.Repository{Host: `github.com`, Owner: `org`, Name: `other`}) {
+    t.Errorf("`org/other` should not use a mirror")
+  }
+
+  expectedForks := []struct {
+    upstream repository.Repository
+    expectedFork repository.Repository
+  }{
+    {repository.Repository{Host: `github.com`, Owner: `upstream-org`, Name: `repo`}, repository.Repository{Host: `github.com`, Owner: `myuser`, Name: `repo`}},
+    {repository.Repository{Host: `gitlab.com`, Owner: `group/sub`, Name: `repo`}, repository.Repository{Host: `gitlab.com`, Owner: `otheruser`, Name: `repo`}},
+  }
+  for _, expectedFork := range expectedForks {
+    fork, err := config.ForkRepository(expectedFork.upstream)
+    if err != nil {
+      t.Errorf("ForkRepository for `%#v` errored: %s", expectedFork.upstream, err.Error())
+      continue
+    }
+    if fork != expectedFork.expectedFork {
+      t.Errorf("ForkRepository for `%#v` expected `%#v` but got `%#v`", expectedFork.upstream, expectedFork.expectedFork, fork)
+    }
+  }
+  expectedHooks := []struct {
+    repository repository.Repository
+    expectedHooks hook.Hooks
+  }{
+    {repository.Repository{Host: `github.com`, Owner: `org`, Name: `monorepo`}, hook.Hooks{PostClone: []string{`make bootstrap`, `npm ci`}, OnEnter: []string{`git fetch`}}},
+    {repository.Repository{Host: `github.com`, Owner: `org`, Name: `other`}, hook.Hooks{PostClone: []string{`make bootstrap`}}},
+    {repository.Repository{Host: `github.com`, Owner: `team`, Name: `tool`}, hook.Hooks{}},
+  }
+  for _, expectedHook := range expectedHooks {
+    if hooks := config.RepositoryHooks(expectedHook.repository); !reflect.DeepEqual(hooks, expectedHook.expectedHooks) {
+      t.Errorf("Hooks for `%#v` expected `%#v` but got `%#v`", expectedHook.repository, expectedHook.expectedHooks, hooks)
+    }
+  }
+
+  if config.Fork.Location != ForkLocationFork {
+    t.Errorf("Fork location expected `%s` but got `%s`", ForkLocationFork, config.Fork.Location)
+  }
+}
+
+func TestLoadMissing(t *testing.T) {
+  config, err := Load(`/not/a/config/file`)
+  if err != nil {
+    t.Fatalf("Load missing file errored: %s", err.Error())
+  }
+  if config.ApiVersion != 1 {
+    t.Errorf("Load missing file expected apiVersion 1 but got %d", config.ApiVersion)
+  }
+  if _, err := config.ForkRepository(repository.Repository{Host: `github.com`, Owner: `upstream-org`, Name: `repo`}); err == nil {
+    t.Errorf("ForkRepository without a fork user should have errored")
+  }
+}
+
+func TestLoadInvalid(t *testing.T) {
+  configFile := writeConfig("apiVersion: 1\nunknownField: true\n", t)
+  defer os.RemoveAll(path.Dir(configFile))
+
+  if _, err := Load(configFile); err == nil {
+    t.Errorf("Load config with unknown field should have errored")
+  }
+
+  configFile = writeConfig("apiVersion: 1\nhosts:\n  git.example.org: unknown\n", t)
+  defer os.RemoveAll(path.Dir(configFile))
+
+  if _, err := Load(configFile); err == nil {
+    t.Errorf("Load config with unknown forge should have errored")
+  }
+
+  configFile = writeConfig("apiVersion: 1\nregistries:\n  unknown: https://registry.example.com\n", t)
+  defer os.RemoveAll(path.Dir(configFile))
+
+  if _, err := Load(configFile); err == nil {
+    t.Errorf("Load config with unknown registry should have errored")
+  }
+
+  configFile = writeConfig("apiVersion: 1\ncloneUrls:\n  '*':\n  - https://{host}/{owner}\n", t)
+  defer os.RemoveAll(path.Dir(configFile))
+
+  if _, err := Load(configFile); err == nil {
+    t.Errorf("Load config with clone URL without name should have errored")
+  }
+}
+
+/** Writes the configFileContents to a config file in a new temporary directory. */
+func writeConfig(configFileContents string, t *testing.T) string {
+  configDirectory, err := ioutil.TempDir(``, `gitcd-config`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  configFile := path.Join(configDirectory, `config.yaml`)
+  err = ioutil.WriteFile(configFile, []byte(configFileContents), 0644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return configFile
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+  "flag"
   "os"
   "fmt"
   "github.com/coollog/gitcd/cmd/gitcd/repository"
@@ -24,6 +25,11 @@ import (
   "strings"
   "github.com/coollog/gitcd/cmd/gitcd/cache"
   "github.com/coollog/gitcd/cmd/gitcd/home"
+  "github.com/coollog/gitcd/cmd/gitcd/config"
+  "github.com/coollog/gitcd/cmd/gitcd/goimport"
+  "github.com/coollog/gitcd/cmd/gitcd/registry"
+  "github.com/coollog/gitcd/cmd/gitcd/hook"
+  "github.com/coollog/gitcd/cmd/gitcd/trust"
   "io/ioutil"
   "path"
 )
@@ -31,7 +37,7 @@ import (
 /** Environment variable hinting that gcd has been installed properly. */
 const GitcdGcd = `GITCD_GCD`
 
-const UsageGitcd = `Quickly navigate to your GitHub repositories.
+const UsageGitcd = `Quickly navigate to your git repositories.
 
 Install 'gcd' to use gitcd smoothly:
 
@@ -46,11 +52,31 @@ Repositories live under $GITCD_HOME. If the repository does not exist, clones th
 
 const UsageGcd = `Usage:
 
-  gcd [repository] - goes to the directory for that repository
+  gcd [flags] [repository] - goes to the directory for that repository
+  gitcd mirror update      - fetches the latest into the mirrors that clones borrow objects from
+  gitcd trust DIRECTORY    - trusts the hooks that the repository at the directory provides, like gitcd trust .
+  gitcd untrust DIRECTORY  - stops trusting the hooks that the repository at the directory provides
+
+Flags (for new clones):
+
+  --depth N           - makes a shallow clone with the last N commits
+  --filter SPEC       - makes a partial clone, like with --filter blob:none
+  --sparse PATH,...   - makes a sparse checkout with only the top-level files and the directories at the paths
+  --fork              - clones your fork of the repository, with the repository as the upstream remote
 
 Examples:
 
   gcd https://github.com/coollog/gitcd
+  gcd git@gitlab.com:coollog/gitcd.git
+  gcd https://github.com/coollog/gitcd/tree/master/cmd
+  gcd coollog/gitcd#12
+  gcd coollog/gitcd@v1.0.0
+  gcd gl:coollog/gitcd
+  gcd golang.org/x/tools/go/packages
+  gcd npm:express
+  gcd --filter blob:none --sparse services/api org/monorepo
+  gcd --fork upstream-org/repo
+  gcd /srv/git/coollog/gitcd.git
   gcd coollog/gitcd
   gcd gitcd
   GITCD_HOME=$GOPATH/src/github.com gcd coollog/gitcd
@@ -58,19 +84,81 @@ Examples:
 Repositories live under $GITCD_HOME. If the repository does not exist, clones the repository.
 `
 
+/** Flags for how to clone. These override the clone options from the config. */
+type cloneFlags struct {
+  depth  int
+  filter string
+  // Whether --sparse was set, and its comma-separated paths.
+  sparse      bool
+  sparsePaths string
+  // Whether to clone the fork of the user instead.
+  fork bool
+}
+
+/** Overrides the options with the flags that were set. */
+func (f cloneFlags) apply(options *repository.CloneOptions) {
+  if f.depth > 0 {
+    options.Depth = f.depth
+  }
+  if len(f.filter) > 0 {
+    options.Filter = f.filter
+  }
+  if f.sparse {
+    options.Sparse, options.SparsePaths = true, nil
+    for _, sparsePath := range strings.Split(f.sparsePaths, `,`) {
+      if len(sparsePath) > 0 {
+        options.SparsePaths = append(options.SparsePaths, sparsePath)
+      }
+    }
+  }
+}
+
 func main() {
-  switch len(os.Args) {
-  case 2:
-    repositoryString := os.Args[1]
-    if gitcd(repositoryString) {
+  var flags cloneFlags
+  flag.IntVar(&flags.depth, `depth`, 0, `makes a shallow clone with the last N commits`)
+  flag.StringVar(&flags.filter, `filter`, ``, `makes a partial clone, like with blob:none`)
+  flag.StringVar(&flags.sparsePaths, `sparse`, ``, `makes a sparse checkout with the comma-separated directories`)
+  flag.BoolVar(&flags.fork, `fork`, false, `clones your fork of the repository`)
+  flag.Usage = func() {
+    fmt.Fprint(os.Stderr, UsageGcd)
+  }
+  flag.Parse()
+  flag.Visit(func(f *flag.Flag) {
+    flags.sparse = flags.sparse || f.Name == `sparse`
+  })
+
+  // Loads the gitcd config, and registers its forges before any command reads repositories, so that all of them treat
+  // the hosts of the forges the same way.
+  gitcdConfig, err := loadConfig()
+  if err != nil {
+    log.Fatal(err)
+  }
+  for host, forge := range gitcdConfig.RepositoryForges() {
+    repository.Forges[host] = forge
+  }
+
+  switch {
+  case flag.NArg() == 1:
+    repositoryString := flag.Arg(0)
+    if gitcd(gitcdConfig, repositoryString, flags) {
+      os.Exit(0)
+    }
+
+  case flag.NArg() == 2 && flag.Arg(0) == `mirror` && flag.Arg(1) == `update`:
+    if updateMirrors(gitcdConfig) {
+      os.Exit(0)
+    }
+
+  case flag.NArg() == 2 && (flag.Arg(0) == `trust` || flag.Arg(0) == `untrust`):
+    if trustHooks(flag.Arg(1), flag.Arg(0) == `trust`) {
       os.Exit(0)
     }
 
   default:
     if len(os.Getenv(GitcdGcd)) > 0 {
-      fmt.Println(UsageGcd)
+      fmt.Print(UsageGcd)
     } else {
-      fmt.Println(UsageGitcd)
+      fmt.Print(UsageGitcd)
     }
 
     err := showClonedRepositories()
@@ -81,8 +169,11 @@ func main() {
   os.Exit(1)
 }
 
-/** Prints the repo directory matching the repositoryString query. Returns true if successful; false if not. */
-func gitcd(repositoryString string) bool {
+/**
+ * Prints the repo directory matching the repositoryString query. The flags apply if the repo needs to be cloned.
+ * Returns true if successful; false if not.
+ */
+func gitcd(gitcdConfig config.Config, repositoryString string, flags cloneFlags) bool {
   // Gets the gitcd home directory.
   gitcdHome, err := home.GitcdHome()
   if err != nil {
@@ -90,8 +181,9 @@ func gitcd(repositoryString string) bool {
     return false
   }
 
-  // If the respository string is just one part, then try to guess the full repository.
-  if !strings.ContainsAny(repositoryString, `/`) {
+  // If the respository string is just one part, then try to guess the full repository. Prefixed repository strings,
+  // like `work:billing`, are not just one part.
+  if !strings.ContainsAny(repositoryString, `/:`) {
     repoName := repositoryString
 
     // Loads the .gitcd file.
@@ -109,10 +201,15 @@ func gitcd(repositoryString string) bool {
     // Tries to find owners for repoName.
     owners := repoCache.FindOwners(repoName)
     for _, owner := range owners {
-      resolvedRepository := repository.Resolve(gitcdHome, repository.Repository{Owner: owner, Name: repoName})
+      repo, err := repository.FromPath(path.Join(owner, repoName))
+      if err != nil {
+        continue
+      }
+      resolvedRepository := repository.Resolve(gitcdHome, repo)
       if !resolvedRepository.Exists() {
         continue
       }
+      runOnEnterHooks(gitcdConfig, resolvedRepository.Directory, repo)
       fmt.Println(resolvedRepository.Directory)
       return true
     }
@@ -122,22 +219,104 @@ func gitcd(repositoryString string) bool {
     return false
   }
 
-  // Parses the repository string into a canonicalized form.
-  canonicalRepository, err := repository.Canonicalize(repositoryString)
+  parser := newParser(gitcdConfig)
+
+  // Parses the repository string into a canonicalized form. Packages, like `npm:express`, resolve to their repository.
+  var target repository.Target
+  if registry.IsPackage(repositoryString) {
+    target, err = (&registry.Resolver{BaseUrls: gitcdConfig.Registries}).Resolve(parser, repositoryString)
+  } else {
+    target, err = parseTarget(parser, gitcdHome, repositoryString, gitcdConfig.GoImportHosts)
+  }
   if err != nil {
     log.Fatal(err)
     return false
   }
 
+  // With --fork, clones the fork of the user instead, into the directory of the repository or of the fork.
+  upstream := target.Repository
+  cloneRepository, cloneUrl := target.Repository, target.CloneUrl
+  if flags.fork {
+    cloneRepository, err = gitcdConfig.ForkRepository(upstream)
+    if err != nil {
+      log.Fatal(err)
+      return false
+    }
+    cloneUrl = repository.ForkUrl(target.CloneUrl, upstream, cloneRepository)
+    if gitcdConfig.Fork.Location == config.ForkLocationFork {
+      target.Repository = cloneRepository
+    }
+  }
+
   // Checks if the repository exists.
-  resolvedRepository := repository.Resolve(gitcdHome, canonicalRepository)
+  resolvedRepository := repository.Resolve(gitcdHome, target.Repository)
+  if resolvedRepository.Exists() {
+    if err := parser.CheckClone(resolvedRepository.Directory, target); err != nil {
+      log.Fatal(err)
+      return false
+    }
+
+    // --fork only clones new forks, but existing clones of forks get the upstream remote if they do not have it.
+    if flags.fork && !repository.HasUpstream(resolvedRepository.Directory) {
+      if gitcdConfig.Fork.Location == config.ForkLocationFork {
+        addUpstream(resolvedRepository.Directory, upstream, target.CloneUrl, gitcdConfig, parser.Rewrites)
+      } else {
+        log.Printf("`%s` is already cloned, so --fork had no effect; move the clone away to clone your fork there\n", resolvedRepository.Directory)
+      }
+    }
+  }
   if !resolvedRepository.Exists() {
-    // Repository doesn't exist, clone it.
-    err := repository.Clone(gitcdHome, repositoryString, canonicalRepository)
+    // Another gitcd might be cloning the repository too, so waits for it and checks again.
+    lock, err := repository.LockDirectory(gitcdHome, resolvedRepository.Directory)
     if err != nil {
-      log.Fatalf("Could not clone repository `%s`:%s", repositoryString, err.Error())
+      log.Fatalf("Could not lock `%s`: %s", resolvedRepository.Directory, err.Error())
       return false
     }
+    if !resolvedRepository.Exists() {
+      // Repository doesn't exist, clone it. Forks clone like the repositories they are forked from.
+      cloneOptions := gitcdConfig.RepositoryCloneOptions(upstream)
+      cloneOptions.Rewrites, cloneOptions.UrlTemplates = parser.Rewrites, gitcdConfig.RepositoryCloneUrlTemplates()
+      flags.apply(&cloneOptions)
+      if gitcdConfig.UsesMirror(upstream) {
+        cloneOptions.MirrorRoot, err = home.MirrorDirectory(gitcdConfig.MirrorDirectory)
+        if err != nil {
+          log.Printf("Could not resolve mirror directory: %s\n", err.Error())
+        }
+      }
+      err := repository.CloneInto(gitcdHome, resolvedRepository.Directory, cloneUrl, cloneRepository, cloneOptions)
+      if err != nil {
+        log.Fatalf("Could not clone repository `%s`: %s", repositoryString, err.Error())
+        return false
+      }
+
+      if flags.fork {
+        addUpstream(resolvedRepository.Directory, upstream, target.CloneUrl, gitcdConfig, parser.Rewrites)
+      }
+
+      // Runs the setup for new clones, like `make bootstrap`.
+      runHooks(hook.PostCloneHook, gitcdConfig, resolvedRepository.Directory, resolvedRepository.Repository)
+    }
+    if err := lock.Unlock(); err != nil {
+      log.Printf("Could not unlock `%s`: %s\n", resolvedRepository.Directory, err.Error())
+    }
+  }
+
+  // Warns about clones of the same repository that differ only in case.
+  caseDuplicates := repository.FindCaseDuplicates(gitcdHome, resolvedRepository.Repository)
+  if len(caseDuplicates) > 1 {
+    log.Printf("`%s` is cloned more than once with different cases: %s\n", resolvedRepository.Repository.Key(), strings.Join(caseDuplicates, `, `))
+    log.Printf("Using `%s`; consider moving your changes there and removing the others\n", resolvedRepository.Repository.Path())
+  }
+
+  // Makes the ref from a web URL available in the clone.
+  refResolved := false
+  if len(target.Ref) > 0 {
+    target, err = repository.ResolveRef(resolvedRepository.Directory, target)
+    if err != nil {
+      log.Println(err)
+    } else {
+      refResolved = true
+    }
   }
 
   // Bumps the repo to the top in the .gitcd file.
@@ -160,11 +339,295 @@ func gitcd(repositoryString string) bool {
     }
   }
 
-  // Prints the repo directory.
-  fmt.Println(resolvedRepository.Directory)
+  // Checks out the pull request in its own worktree.
+  directory := resolvedRepository.Directory
+  if target.PullRequest > 0 {
+    // `gcd` runs gitcd twice, so only the first run, whose output is not the directory to go to, updates the pull request.
+    directory, err = repository.CheckoutPullRequest(resolvedRepository, target.PullRequest, len(os.Getenv(GitcdGcd)) > 0)
+    if err != nil {
+      log.Fatalf("Could not check out pull request #%d of `%s`: %s", target.PullRequest, repositoryString, err.Error())
+      return false
+    }
+  }
+
+  // Checks out the ref from a web URL in its own worktree, unless the clone has it checked out already.
+  if refResolved && target.PullRequest == 0 && !repository.IsCheckedOut(resolvedRepository.Directory, target.Ref) {
+    directory, err = repository.CheckoutWorktree(resolvedRepository, target.Ref)
+    if err != nil {
+      log.Printf("Could not check out `%s` of `%s`: %s\n", target.Ref, repositoryString, err.Error())
+      directory = resolvedRepository.Directory
+    }
+  }
+
+  // Checks out the ref in its own worktree.
+  if len(target.WorktreeRef) > 0 {
+    directory, err = repository.CheckoutWorktree(resolvedRepository, target.WorktreeRef)
+    if err != nil {
+      log.Fatalf("Could not check out `%s` of `%s`: %s", target.WorktreeRef, repositoryString, err.Error())
+      return false
+    }
+  }
+
+  // Warns about submodules that need `git submodule update`.
+  warnUnsyncedSubmodules(directory)
+
+  runOnEnterHooks(gitcdConfig, directory, resolvedRepository.Repository)
+
+  // Prints the repo directory, or the directory within it that the target points to.
+  fmt.Println(targetDirectory(directory, target))
+  return true
+}
+
+/**
+ * Adds the upstream, which the clone of its fork at directory is forked from, as the upstream remote. Clones it with the
+ * upstreamCloneUrl, or with the first clone URL for the upstream from the config.
+ */
+func addUpstream(directory string, upstream repository.Repository, upstreamCloneUrl string, gitcdConfig config.Config, rewrites repository.Rewrites) {
+  upstreamUrl := upstreamCloneUrl
+  if upstreamUrls := gitcdConfig.RepositoryCloneUrlTemplates().Urls(upstream); len(upstreamUrl) == 0 && len(upstreamUrls) > 0 {
+    upstreamUrl = upstreamUrls[0]
+  }
+  if err := repository.AddUpstream(directory, rewrites.Apply(upstreamUrl)); err != nil {
+    log.Printf("Could not add `%s` as the upstream of `%s`: %s\n", repository.RedactUrl(upstreamUrl), directory, err.Error())
+  }
+}
+
+/**
+ * Runs the on-enter hooks in the directory of the clone of repo. `gcd` runs gitcd twice, first with GITCD_GCD set and
+ * then to get the directory to go to, so the hooks run only on the second run.
+ */
+func runOnEnterHooks(gitcdConfig config.Config, directory string, repo repository.Repository) {
+  if len(os.Getenv(GitcdGcd)) > 0 {
+    return
+  }
+  runHooks(hook.OnEnterHook, gitcdConfig, directory, repo)
+}
+
+/**
+ * Runs the kind of hook in the checkout of repo at directory, and logs the ones that failed. The hooks from the config
+ * run first, and then the hooks that the checkout provides, if the user trusts them. Trust is for the repository, not
+ * for what pull requests change, so the hooks that pull request worktrees provide never run.
+ */
+func runHooks(kind hook.Kind, gitcdConfig config.Config, directory string, repo repository.Repository) {
+  commands := gitcdConfig.RepositoryHooks(repo).Commands(kind)
+
+  providedHooks, hooksFileContents, err := hook.LoadRepositoryHooks(directory)
+  if err != nil {
+    log.Printf("Could not load the hooks of `%s`: %s\n", directory, err.Error())
+  } else if providedCommands := providedHooks.Commands(kind); len(providedCommands) > 0 {
+    if repository.IsPullRequestWorktree(directory) {
+      log.Printf("Not running the %s hooks in `%s`, since pull requests can change them and what they run\n", kind, path.Join(directory, hook.RepositoryHooksFilename))
+    } else if isTrusted(repo, hooksFileContents) {
+      commands = append(commands, providedCommands...)
+    } else {
+      log.Printf("Not running the %s hooks in `%s`, since they are new or changed; review them and run `gitcd trust %s` to trust them\n", kind, path.Join(directory, hook.RepositoryHooksFilename), directory)
+    }
+  }
+
+  for _, err := range hook.RunAll(directory, repo, commands) {
+    log.Printf("Could not run %s hook in `%s`: %s\n", kind, directory, err.Error())
+  }
+}
+
+/** Checks if the user trusts the hooks file of the repo with the hooksFileContents. */
+func isTrusted(repo repository.Repository, hooksFileContents []byte) bool {
+  trustFile, err := home.GitcdTrustFile()
+  if err != nil {
+    log.Printf("Could not resolve trust file: %s\n", err.Error())
+    return false
+  }
+  trusted, err := trust.Load(trustFile)
+  if err != nil {
+    log.Printf("Could not load trust file: %s\n", err.Error())
+    return false
+  }
+  return trusted.IsTrusted(repo, hooksFileContents)
+}
+
+/**
+ * Trusts the hooks file of the checkout that the directory is in, or stops trusting the hooks of its repository if
+ * trusted is false. Returns true if successful.
+ */
+func trustHooks(directory string, trusted bool) bool {
+  gitcdHome, err := home.GitcdHome()
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+  resolvedRepository, checkoutDirectory, err := repository.FindClone(gitcdHome, directory)
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+
+  trustFile, err := home.GitcdTrustFile()
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+  trustedHooks, err := trust.Load(trustFile)
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+
+  if trusted {
+    if repository.IsPullRequestWorktree(checkoutDirectory) {
+      log.Printf("Not trusting the hooks in `%s`, since they are from a pull request; trust them in `%s` instead\n", checkoutDirectory, resolvedRepository.Directory)
+      return false
+    }
+    _, hooksFileContents, err := hook.LoadRepositoryHooks(checkoutDirectory)
+    if err != nil {
+      log.Fatal(err)
+      return false
+    }
+    if hooksFileContents == nil {
+      log.Printf("`%s` has no %s file to trust\n", checkoutDirectory, hook.RepositoryHooksFilename)
+      return false
+    }
+    trustedHooks.Trust(resolvedRepository.Repository, hooksFileContents)
+    log.Printf("Trusted the hooks in `%s`\n", path.Join(checkoutDirectory, hook.RepositoryHooksFilename))
+  } else {
+    trustedHooks.Untrust(resolvedRepository.Repository)
+    log.Printf("No longer trusting the hooks of `%s`\n", resolvedRepository.Directory)
+  }
+
+  if err := trust.Save(trustFile, trustedHooks); err != nil {
+    log.Fatal(err)
+    return false
+  }
   return true
 }
 
+/** Logs the submodules of the clone at directory that are not in sync. */
+func warnUnsyncedSubmodules(directory string) {
+  submodules, err := repository.FindUnsyncedSubmodules(directory)
+  if err != nil {
+    log.Printf("Could not check the submodules of `%s`: %s\n", directory, err.Error())
+    return
+  }
+  for _, submodule := range submodules {
+    log.Printf("Submodule `%s` is %s; run `git submodule update --init --recursive` to update it\n", submodule.Path, submodule.State)
+  }
+}
+
+/** Updates all the mirrors in the mirror directory from the gitcdConfig. Returns true if all of them updated. */
+func updateMirrors(gitcdConfig config.Config) bool {
+  mirrorRoot, err := home.MirrorDirectory(gitcdConfig.MirrorDirectory)
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+
+  mirrors, err := repository.FindMirrors(mirrorRoot)
+  if err != nil {
+    log.Fatal(err)
+    return false
+  }
+
+  updated := true
+  for _, mirror := range mirrors {
+    log.Printf("Updating mirror `%s`...\n", mirror)
+    if err := repository.UpdateMirror(mirror); err != nil {
+      log.Printf("Could not update mirror `%s`: %s\n", mirror, err.Error())
+      updated = false
+    }
+  }
+  return updated
+}
+
+/** Loads the gitcd config file. */
+func loadConfig() (config.Config, error) {
+  gitcdConfigFile, err := home.GitcdConfigFile()
+  if err != nil {
+    return config.Config{}, err
+  }
+  return config.Load(gitcdConfigFile)
+}
+
+/** Creates a Parser with the URL rewrites from the git config and the gitcdConfig, and the SSH host aliases. */
+func newParser(gitcdConfig config.Config) *repository.Parser {
+  rewrites, err := repository.GitRewrites()
+  if err != nil {
+    log.Printf("Could not read URL rewrites from git config: %s\n", err.Error())
+  }
+  rewrites = append(rewrites, gitcdConfig.RepositoryRewrites()...)
+
+  var sshHosts map[string]string
+  sshConfigFile, err := home.SshConfigFile()
+  if err == nil {
+    sshHosts, err = repository.LoadSshHosts(sshConfigFile)
+  }
+  if err != nil {
+    log.Printf("Could not read SSH host aliases: %s\n", err.Error())
+  }
+
+  return &repository.Parser{
+    Rewrites:   rewrites,
+    SshHosts:   sshHosts,
+    Prefixes:   gitcdConfig.RepositoryPrefixes(),
+    LocalPaths: gitcdConfig.LocalPaths,
+  }
+}
+
+/**
+ * Parses the repositoryString with the parser. Repository strings that look like Go import paths resolve to the
+ * repository containing the package if they do not parse as repositories, or if they are on Go import hosts (see
+ * goimport.IsImportHost) and not already cloned. Other import paths can use the `go:` prefix.
+ */
+func parseTarget(parser *repository.Parser, gitcdHome string, repositoryString string, goImportHosts []string) (repository.Target, error) {
+  target, err := parser.Parse(repositoryString)
+  if !goimport.IsImportPath(repositoryString) {
+    return target, err
+  }
+  if err == nil {
+    // Self-hosted forges, like GitLab, can answer go-get requests for nested groups with the wrong repository.
+    resolvedRepository := repository.Resolve(gitcdHome, target.Repository)
+    if !goimport.IsImportHost(target.Repository.Host, goImportHosts) || resolvedRepository.Exists() {
+      return target, nil
+    }
+  }
+
+  importTarget, importErr := (&goimport.Resolver{}).Resolve(parser, repositoryString)
+  if importErr == nil {
+    return importTarget, nil
+  }
+
+  // Repositories on Go import hosts might not serve go-import meta tags.
+  if err == nil {
+    return target, nil
+  }
+  return target, err
+}
+
+/** Gets the directory within the repo directory that the target points to. */
+func targetDirectory(directory string, target repository.Target) string {
+  if len(target.Path) == 0 {
+    return directory
+  }
+
+  // Goes to the directory containing files.
+  targetPath := target.Path
+  if target.IsFile {
+    targetPath = path.Dir(targetPath)
+  }
+
+  pathDirectory := path.Join(directory, targetPath)
+
+  // Sparse checkouts check out the directory on demand.
+  if _, err := os.Stat(pathDirectory); os.IsNotExist(err) && repository.IsSparse(directory) {
+    if err := repository.AddSparsePath(directory, targetPath); err != nil {
+      log.Printf("Could not add `%s` to the sparse checkout of `%s`: %s\n", targetPath, directory, err.Error())
+    }
+  }
+
+  if fileInfo, err := os.Stat(pathDirectory); err != nil || !fileInfo.IsDir() {
+    log.Printf("`%s` is not in the checkout of `%s` at ref `%s`\n", targetPath, directory, target.Ref)
+    return directory
+  }
+  return pathDirectory
+}
+
 /** Shows all the cloned repos. */
 func showClonedRepositories() error {
   gitcdHome, err := home.GitcdHome()
@@ -176,37 +639,90 @@ func showClonedRepositories() error {
     return nil
   }
 
-  var clonedRepos []repository.Repository
-  // Lists all the owner directories.
-  fileInfos, err := ioutil.ReadDir(gitcdHome)
+  clonedRepos, err := listRepositories(gitcdHome, ``)
   if err != nil {
     return err
   }
-  for _, fileInfo := range fileInfos {
-    if fileInfo.Mode().IsDir() {
-      repoOwner := fileInfo.Name()
 
-      // Lists all the owner/name directories.
-      fileInfos, err := ioutil.ReadDir(path.Join(gitcdHome, fileInfo.Name()))
-      if err != nil {
-        return err
+  if len(clonedRepos) > 0 {
+    fmt.Println()
+    // Counts clones of the same repository that differ only in case.
+    keyCounts := make(map[string]int)
+    for _, repo := range clonedRepos {
+      keyCounts[repo.Key()]++
+    }
+
+    fmt.Println("Cloned repositories:")
+    for _, repo := range clonedRepos {
+      var notes []string
+      if keyCounts[repo.Key()] > 1 {
+        notes = append(notes, `duplicate clone that differs only in case`)
       }
-      for _, fileInfo := range fileInfos {
-        if fileInfo.Mode().IsDir() {
-          repoName := fileInfo.Name()
-          clonedRepos = append(clonedRepos, repository.Repository{Owner: repoOwner, Name: repoName})
+      if submodules, err := repository.FindUnsyncedSubmodules(path.Join(gitcdHome, repo.Path())); err == nil && len(submodules) > 0 {
+        notes = append(notes, `submodules out of sync`)
+      }
+      partialNotes := []struct {
+        note string
+        isPartial func(string) bool
+      }{{`shallow`, repository.IsShallow}, {`partial`, repository.IsPartial}, {`sparse`, repository.IsSparse}}
+      for _, partialNote := range partialNotes {
+        if partialNote.isPartial(path.Join(gitcdHome, repo.Path())) {
+          notes = append(notes, partialNote.note)
         }
       }
+      if len(notes) > 0 {
+        fmt.Printf("\t%s (%s)\n", repo.Path(), strings.Join(notes, `; `))
+      } else {
+        fmt.Printf("\t%s\n", repo.Path())
+      }
+
+      // Lists the worktrees of the repo under it.
+      resolvedRepository := repository.ResolvedRepository{Repository: repo, Directory: path.Join(gitcdHome, repo.Path())}
+      for _, worktree := range repository.ListWorktrees(resolvedRepository) {
+        fmt.Printf("\t\t@%s\n", worktree)
+      }
     }
   }
 
-  if len(clonedRepos) > 0 {
-    fmt.Println()
-    fmt.Println("Cloned repositories:")
-    for _, repo := range clonedRepos {
-      fmt.Printf("\t%s/%s\n", repo.Owner, repo.Name)
+  return nil
+}
+
+/**
+ * Lists the cloned repos under the directory at repositoryPath relative to the gitcdHome. Namespaces can be nested, so
+ * this descends until it finds a directory that is a git repository.
+ */
+func listRepositories(gitcdHome string, repositoryPath string) ([]repository.Repository, error) {
+  directory := path.Join(gitcdHome, repositoryPath)
+
+  if fileInfo, err := os.Stat(path.Join(directory, `.git`)); err == nil {
+    // Worktrees have a .git file rather than a .git directory.
+    if !fileInfo.IsDir() {
+      return nil, nil
+    }
+
+    repo, err := repository.FromPath(repositoryPath)
+    if err != nil {
+      return nil, nil
     }
+    return []repository.Repository{repo}, nil
   }
 
-  return nil
+  fileInfos, err := ioutil.ReadDir(directory)
+  if err != nil {
+    return nil, err
+  }
+  var clonedRepos []repository.Repository
+  for _, fileInfo := range fileInfos {
+    // Skips files and hidden directories in the gitcd home. Repository names themselves can start with a dot.
+    if !fileInfo.Mode().IsDir() || (len(repositoryPath) == 0 && strings.HasPrefix(fileInfo.Name(), `.`)) {
+      continue
+    }
+
+    repos, err := listRepositories(gitcdHome, path.Join(repositoryPath, fileInfo.Name()))
+    if err != nil {
+      return nil, err
+    }
+    clonedRepos = append(clonedRepos, repos...)
+  }
+  return clonedRepos, nil
 }
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package main
+
+import (
+  "testing"
+  "reflect"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+func TestCloneFlagsApply(t *testing.T) {
+  defaults := repository.CloneOptions{Depth: 1, Filter: `blob:none`, Sparse: true, SparsePaths: []string{`services/api`}}
+
+  expectedOptions := []struct {
+    flags cloneFlags
+    expectedOptions repository.CloneOptions
+  }{
+    {cloneFlags{}, defaults},
+    {cloneFlags{depth: 10}, repository.CloneOptions{Depth: 10, Filter: `blob:none`, Sparse: true, SparsePaths: []string{`services/api`}}},
+    {cloneFlags{filter: `tree:0`}, repository.CloneOptions{Depth: 1, Filter: `tree:0`, Sparse: true, SparsePaths: []string{`services/api`}}},
+    {cloneFlags{sparse: true, sparsePaths: `libs/common,,services/web`}, repository.CloneOptions{Depth: 1, Filter: `blob:none`, Sparse: true, SparsePaths: []string{`libs/common`, `services/web`}}},
+    {cloneFlags{sparse: true}, repository.CloneOptions{Depth: 1, Filter: `blob:none`, Sparse: true}},
+  }
+
+  for _, expectedOption := range expectedOptions {
+    options := defaults
+    expectedOption.flags.apply(&options)
+    if !reflect.DeepEqual(options, expectedOption.expectedOptions) {
+      t.Errorf("Applying `%#v` expected `%#v` but got `%#v`", expectedOption.flags, expectedOption.expectedOptions, options)
+    }
+  }
+}
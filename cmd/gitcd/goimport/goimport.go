@@ -0,0 +1,210 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package goimport
+
+import (
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "net/http"
+  "net/url"
+  "regexp"
+  "strings"
+  "time"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+// Go import paths, like `golang.org/x/tools/go/packages`, name a package in a repository. The repository is found with
+// the `go-import` meta tag served at the import path with `?go-get=1`. See https://golang.org/cmd/go/#hdr-Remote_import_paths.
+
+/** Fetches the contents at the url. */
+type Fetcher func(url string) ([]byte, error)
+
+/** Resolves Go import paths to repositories. */
+type Resolver struct {
+  // Fetches the `?go-get=1` pages. Defaults to HttpFetch without extra headers.
+  Fetch Fetcher
+}
+
+/** The root of an import path, from a `go-import` meta tag. */
+type Root struct {
+  // The import path of the root, like `golang.org/x/tools`.
+  ImportPrefix string
+  Vcs          string
+  RepoUrl      string
+}
+
+var MetaRegex = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+var NameAttributeRegex = regexp.MustCompile(`(?is)\sname\s*=\s*["']?go-import["'\s/>]`)
+var ContentAttributeRegex = regexp.MustCompile(`(?is)\scontent\s*=\s*("(?P<double>[^"]*)"|'(?P<single>[^']*)')`)
+
+/** The schemes of repository URLs from `go-import` meta tags that are cloned, like `cmd/go` allows. */
+var CloneSchemes = map[string]bool{`https`: true, `ssh`: true, `git`: true, `git+ssh`: true}
+
+/**
+ * Hosts of Go import paths that are not shaped like repositories, like `golang.org/x/tools/go/packages`. Import paths on
+ * other hosts only resolve with `go-import` meta tags if they do not parse as repositories or have the `go:` prefix.
+ */
+var DefaultImportHosts = []string{
+  `golang.org`,
+  `google.golang.org`,
+  `cloud.google.com`,
+  `go.uber.org`,
+  `gopkg.in`,
+  `k8s.io`,
+  `sigs.k8s.io`,
+  `go.opentelemetry.io`,
+  `go.etcd.io`,
+  `go.mongodb.org`,
+  `gocloud.dev`,
+  `gotest.tools`,
+  `honnef.co`,
+}
+
+/** Checks if import paths on the host resolve with `go-import` meta tags. The extraHosts add to the DefaultImportHosts. */
+func IsImportHost(host string, extraHosts []string) bool {
+  for _, importHost := range append(append([]string{}, DefaultImportHosts...), extraHosts...) {
+    if strings.EqualFold(host, importHost) {
+      return true
+    }
+  }
+  return false
+}
+
+/** Checks if the repositoryString looks like a Go import path, like `host.tld/path`, rather than a URL. */
+func IsImportPath(repositoryString string) bool {
+  parts := strings.Split(repositoryString, `/`)
+  return len(parts) >= 2 && strings.Contains(parts[0], `.`) && !strings.ContainsAny(repositoryString, `:@#?`)
+}
+
+/**
+ * Resolves the importPath to a repository.Target for the repository containing the package. The target Path is the
+ * directory of the package within the repository.
+ *
+ * Import paths on known hosts with flat namespaces, like `github.com/owner/name/package`, resolve without fetching.
+ */
+func (r *Resolver) Resolve(parser *repository.Parser, importPath string) (repository.Target, error) {
+  parts := strings.Split(importPath, `/`)
+  host := parts[0]
+
+  if parser.IsKnownHost(host) && !repository.FindForge(host).Nested && len(parts) >= 3 {
+    target, err := parser.Parse(strings.Join(parts[:3], `/`))
+    if err != nil {
+      return repository.Target{}, err
+    }
+    target.Path = strings.Join(parts[3:], `/`)
+    return target, nil
+  }
+
+  root, err := r.FindRoot(importPath)
+  if err != nil {
+    return repository.Target{}, err
+  }
+  // The repository URL comes from whatever page the import path serves, so it cannot be a local path or a git option.
+  repoUrl, err := url.Parse(root.RepoUrl)
+  if err != nil || strings.HasPrefix(root.RepoUrl, `-`) || !CloneSchemes[repoUrl.Scheme] || len(repoUrl.Host) == 0 {
+    return repository.Target{}, errors.New(fmt.Sprintf("Repository URL `%s` for `%s` is not an https, ssh, git, or git+ssh URL", root.RepoUrl, importPath))
+  }
+
+  // Uses the repository URL for the identity, like github.com/uber-go/zap for go.uber.org/zap. Some repository URLs
+  // are not shaped like repositories, like https://go.googlesource.com/tools, so those use the root import path.
+  target, err := parser.Parse(root.RepoUrl)
+  if err != nil {
+    target, err = parser.Parse(root.ImportPrefix)
+    if err != nil {
+      return repository.Target{}, err
+    }
+  }
+  target.CloneUrl = root.RepoUrl
+  target.Path = strings.TrimPrefix(strings.TrimPrefix(importPath, root.ImportPrefix), `/`)
+  return target, nil
+}
+
+/** Finds the root of the importPath from the `go-import` meta tags served for it. */
+func (r *Resolver) FindRoot(importPath string) (Root, error) {
+  fetch := r.Fetch
+  if fetch == nil {
+    fetch = func(url string) ([]byte, error) {
+      return HttpFetch(url, nil)
+    }
+  }
+
+  page, err := fetch(`https://` + importPath + `?go-get=1`)
+  if err != nil {
+    return Root{}, err
+  }
+
+  for _, root := range ParseMetaImports(string(page)) {
+    if root.Vcs != `git` {
+      continue
+    }
+    if importPath == root.ImportPrefix || strings.HasPrefix(importPath, root.ImportPrefix+`/`) {
+      return root, nil
+    }
+  }
+  return Root{}, errors.New(fmt.Sprintf("No git go-import meta tag found for `%s`", importPath))
+}
+
+/** Parses the `go-import` meta tags in the page. */
+func ParseMetaImports(page string) []Root {
+  var roots []Root
+  for _, meta := range MetaRegex.FindAllString(page, -1) {
+    if !NameAttributeRegex.MatchString(meta) {
+      continue
+    }
+    contentMatches := ContentAttributeRegex.FindStringSubmatch(meta)
+    if contentMatches == nil {
+      continue
+    }
+
+    // The content is `import-prefix vcs repo-root`.
+    content := strings.Fields(contentMatches[2] + contentMatches[3])
+    if len(content) < 3 {
+      continue
+    }
+    roots = append(roots, Root{content[0], content[1], content[2]})
+  }
+  return roots
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+/** The User-Agent that HttpFetch sends. Registries like crates.io require one. */
+const UserAgent = `gitcd (https://github.com/coollog/gitcd)`
+
+/** Fetches the contents at the url over HTTP, sending the headers, if any, along with the UserAgent. */
+func HttpFetch(url string, headers map[string]string) ([]byte, error) {
+  request, err := http.NewRequest(http.MethodGet, url, nil)
+  if err != nil {
+    return nil, err
+  }
+  request.Header.Set(`User-Agent`, UserAgent)
+  for name, value := range headers {
+    request.Header.Set(name, value)
+  }
+
+  response, err := httpClient.Do(request)
+  if err != nil {
+    return nil, err
+  }
+  defer response.Body.Close()
+
+  if response.StatusCode != http.StatusOK {
+    return nil, errors.New(fmt.Sprintf("Fetching `%s` failed: %s", url, response.Status))
+  }
+  return ioutil.ReadAll(response.Body)
+}
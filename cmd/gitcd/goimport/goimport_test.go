@@ -0,0 +1,191 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package goimport
+
+import (
+  "testing"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "net/url"
+  "strings"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+/** Serves go-import meta tags like golang.org and go.uber.org do. */
+func newGoImportServer() *httptest.Server {
+  metaImports := map[string]string{
+    `/x/tools`: `golang.org/x/tools git https://go.googlesource.com/tools`,
+    `/zap`:     `go.uber.org/zap git https://github.com/uber-go/zap`,
+    `/modonly`: `example.com/modonly mod https://proxy.example.com`,
+    `/option`:  `example.com/option git --upload-pack=touch`,
+    `/file`:    `example.com/file git file:///srv/git/file`,
+    `/http`:    `example.com/http git http://example.com/http`,
+  }
+
+  return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Query().Get(`go-get`) != `1` {
+      http.NotFound(w, r)
+      return
+    }
+    for root, content := range metaImports {
+      if r.URL.Path == root || strings.HasPrefix(r.URL.Path, root+`/`) {
+        fmt.Fprintf(w, "<html><head>\n<meta name=\"go-import\" content=\"%s\">\n</head></html>", content)
+        return
+      }
+    }
+    http.NotFound(w, r)
+  }))
+}
+
+func TestResolve(t *testing.T) {
+  server := newGoImportServer()
+  defer server.Close()
+
+  // Sends all the fetches to the test server, keeping the path.
+  resolver := Resolver{Fetch: func(fetchUrl string) ([]byte, error) {
+    parsedUrl, err := url.Parse(fetchUrl)
+    if err != nil {
+      return nil, err
+    }
+    return HttpFetch(server.URL + parsedUrl.RequestURI(), nil)
+  }}
+  parser := &repository.Parser{}
+
+  expectedTargets := []struct {
+    importPath string
+    expectedTarget repository.Target
+  }{
+    {`golang.org/x/tools/go/packages`, repository.Target{
+      Repository: repository.Repository{Host: `golang.org`, Owner: `x`, Name: `tools`},
+      CloneUrl: `https://go.googlesource.com/tools`,
+      Path: `go/packages`,
+    }},
+    {`go.uber.org/zap`, repository.Target{
+      Repository: repository.Repository{Host: `github.com`, Owner: `uber-go`, Name: `zap`},
+      CloneUrl: `https://github.com/uber-go/zap`,
+    }},
+    {`go.uber.org/zap/zapcore`, repository.Target{
+      Repository: repository.Repository{Host: `github.com`, Owner: `uber-go`, Name: `zap`},
+      CloneUrl: `https://github.com/uber-go/zap`,
+      Path: `zapcore`,
+    }},
+    {`github.com/coollog/gitcd/cmd/gitcd`, repository.Target{
+      Repository: repository.Repository{Host: `github.com`, Owner: `coollog`, Name: `gitcd`},
+      Path: `cmd/gitcd`,
+    }},
+  }
+
+  for _, expectedTarget := range expectedTargets {
+    target, err := resolver.Resolve(parser, expectedTarget.importPath)
+    if err != nil {
+      t.Errorf("Resolve `%s` errored: %s", expectedTarget.importPath, err.Error())
+      continue
+    }
+    if target != expectedTarget.expectedTarget {
+      t.Errorf("Resolve `%s` expected `%#v` but got `%#v`", expectedTarget.importPath, expectedTarget.expectedTarget, target)
+    }
+  }
+
+  // Repository URLs come from arbitrary pages, so only URLs that cmd/go clones are cloned.
+  for _, importPath := range []string{`example.com/modonly`, `example.com/notfound`, `example.com/option`, `example.com/file`, `example.com/http`} {
+    if _, err := resolver.Resolve(parser, importPath); err == nil {
+      t.Errorf("Resolve `%s` should have errored", importPath)
+    }
+  }
+}
+
+func TestParseMetaImports(t *testing.T) {
+  page := `<!DOCTYPE html>
+<html>
+<head>
+<meta name="go-source" content="go.uber.org/zap https://github.com/uber-go/zap https://github.com/uber-go/zap/tree/master{/dir} https://github.com/uber-go/zap/tree/master{/dir}/{file}#L{line}">
+<meta content='go.uber.org/zap git https://github.com/uber-go/zap' name='go-import' />
+</head>
+</html>`
+
+  roots := ParseMetaImports(page)
+  expectedRoot := Root{`go.uber.org/zap`, `git`, `https://github.com/uber-go/zap`}
+  if len(roots) != 1 || roots[0] != expectedRoot {
+    t.Errorf("ParseMetaImports expected `%#v` but got `%#v`", expectedRoot, roots)
+  }
+}
+
+func TestIsImportPath(t *testing.T) {
+  expectedImportPaths := map[string]bool{
+    `golang.org/x/tools`: true,
+    `github.com/coollog/gitcd`: true,
+    `coollog/gitcd`: false,
+    `https://github.com/coollog/gitcd`: false,
+    `git@github.com:coollog/gitcd`: false,
+    `golang.org`: false,
+  }
+  for repositoryString, expected := range expectedImportPaths {
+    if IsImportPath(repositoryString) != expected {
+      t.Errorf("IsImportPath `%s` expected %t", repositoryString, expected)
+    }
+  }
+}
+
+func TestIsImportHost(t *testing.T) {
+  expectedImportHosts := map[string]bool{
+    `golang.org`: true,
+    `Go.Uber.org`: true,
+    `go.example.com`: true,
+    `git.corp.example.com`: false,
+    `github.com`: false,
+  }
+  for host, expected := range expectedImportHosts {
+    if IsImportHost(host, []string{`go.example.com`}) != expected {
+      t.Errorf("IsImportHost `%s` expected %t", host, expected)
+    }
+  }
+}
+
+func TestHttpFetch(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != `/found` {
+      http.NotFound(w, r)
+      return
+    }
+    fmt.Fprintf(w, "%s %s", r.Header.Get(`User-Agent`), r.Header.Get(`Accept`))
+  }))
+  defer server.Close()
+
+  expectedContents := []struct {
+    headers map[string]string
+    expectedContents string
+  }{
+    {nil, UserAgent + ` `},
+    {map[string]string{`Accept`: `application/json`}, UserAgent + ` application/json`},
+  }
+
+  for _, expectedContent := range expectedContents {
+    contents, err := HttpFetch(server.URL + `/found`, expectedContent.headers)
+    if err != nil {
+      t.Errorf("HttpFetch with `%#v` errored: %s", expectedContent.headers, err.Error())
+      continue
+    }
+    if string(contents) != expectedContent.expectedContents {
+      t.Errorf("HttpFetch with `%#v` expected `%s` but got `%s`", expectedContent.headers, expectedContent.expectedContents, contents)
+    }
+  }
+
+  if _, err := HttpFetch(server.URL + `/missing`, nil); err == nil {
+    t.Errorf("HttpFetch of a missing page should have errored")
+  }
+}
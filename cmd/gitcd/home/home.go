@@ -31,6 +31,15 @@ const GitcdHomeEnvvar = `GITCD_HOME`
 /** Name of the .gitcd file. */
 const GitcdFilename = `.gitcd`
 
+/** Name of the file of trusted hooks, next to the .gitcd file. */
+const GitcdTrustFilename = `.gitcd-trust`
+
+/** Environment variable defining the gitcd config file. */
+const GitcdConfigEnvvar = `GITCD_CONFIG`
+
+/** Name of the gitcd config file in the user home directory. */
+const GitcdConfigFilename = `.gitcd.yaml`
+
 /** Gets the gitcd home directory. */
 func GitcdHome() (string, error) {
   gitcdHome := os.Getenv(GitcdHomeEnvvar)
@@ -56,6 +65,61 @@ func GitcdFile() (string, error) {
   return path.Join(gitcdHome, GitcdFilename), nil
 }
 
+/** Gets the file of trusted hooks. */
+func GitcdTrustFile() (string, error) {
+  gitcdHome, err := GitcdHome()
+  if err != nil {
+    return ``, err
+  }
+  return path.Join(gitcdHome, GitcdTrustFilename), nil
+}
+
+/** Gets the gitcd config file. The config is shared by all gitcd homes, so it lives in the user home directory. */
+func GitcdConfigFile() (string, error) {
+  gitcdConfig := os.Getenv(GitcdConfigEnvvar)
+  if len(gitcdConfig) > 0 {
+    return absolute(gitcdConfig)
+  }
+
+  userHome, err := homedir.Dir()
+  if err != nil {
+    return ``, err
+  }
+  return path.Join(userHome, GitcdConfigFilename), nil
+}
+
+/** Name of the default mirror directory in the user home directory. */
+const MirrorDirectoryName = `.gitcd-mirrors`
+
+/**
+ * Gets the directory of the repository mirrors. The mirrors are shared by all gitcd homes, so they live in the user home
+ * directory unless the config sets the configuredDirectory, which can start with `~`.
+ */
+func MirrorDirectory(configuredDirectory string) (string, error) {
+  if len(configuredDirectory) > 0 {
+    expandedDirectory, err := homedir.Expand(configuredDirectory)
+    if err != nil {
+      return ``, err
+    }
+    return absolute(expandedDirectory)
+  }
+
+  userHome, err := homedir.Dir()
+  if err != nil {
+    return ``, err
+  }
+  return path.Join(userHome, MirrorDirectoryName), nil
+}
+
+/** Gets the SSH config file of the user. */
+func SshConfigFile() (string, error) {
+  userHome, err := homedir.Dir()
+  if err != nil {
+    return ``, err
+  }
+  return path.Join(userHome, `.ssh`, `config`), nil
+}
+
 /** Gets the absolute gitcd home. */
 func absolute(gitcdHome string) (string, error){
   absoluteGitcdHome, err := filepath.Abs(gitcdHome)
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package hook
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "path"
+  "runtime"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+// Hooks are shell commands, like `make bootstrap`, that run in a repository after gitcd clones it or each time gitcd
+// goes to it. Their output goes to stderr, since gitcd prints the directory to go to on stdout.
+
+/** Environment variables set for hooks. */
+const (
+  // The repository, like `github.com/coollog/gitcd`.
+  RepositoryEnvvar = `GITCD_REPOSITORY`
+  // The directory of the clone.
+  DirectoryEnvvar = `GITCD_DIRECTORY`
+)
+
+/** The kinds of hooks, by when they run. */
+type Kind int
+
+const (
+  // Runs in new clones.
+  PostCloneHook Kind = iota
+  // Runs each time gitcd goes to the repository.
+  OnEnterHook
+)
+
+func (k Kind) String() string {
+  if k == OnEnterHook {
+    return `on-enter`
+  }
+  return `post-clone`
+}
+
+/** The commands to run for a repository. */
+type Hooks struct {
+  PostClone []string `yaml:"postClone"`
+  OnEnter   []string `yaml:"onEnter"`
+}
+
+/** Gets the commands of the kind of hook. */
+func (h Hooks) Commands(kind Kind) []string {
+  if kind == OnEnterHook {
+    return h.OnEnter
+  }
+  return h.PostClone
+}
+
+/** Runs the command with the shell in the directory of the clone of repo. */
+func Run(directory string, repo repository.Repository, command string) error {
+  shell, shellFlag := `sh`, `-c`
+  if runtime.GOOS == `windows` {
+    shell, shellFlag = `cmd`, `/C`
+  }
+
+  cmd := exec.Command(shell, shellFlag, command)
+  cmd.Dir = directory
+  cmd.Env = append(os.Environ(),
+    RepositoryEnvvar+`=`+path.Join(repo.HostOrDefault(), repo.Owner, repo.Name),
+    DirectoryEnvvar+`=`+directory)
+  cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+  if err := cmd.Run(); err != nil {
+    return errors.New(fmt.Sprintf("Hook `%s` failed: %s", command, err.Error()))
+  }
+  return nil
+}
+
+/** Runs the commands in order in the directory of the clone of repo. Returns the errors of the commands that failed. */
+func RunAll(directory string, repo repository.Repository, commands []string) []error {
+  var errs []error
+  for _, command := range commands {
+    if err := Run(directory, repo, command); err != nil {
+      errs = append(errs, err)
+    }
+  }
+  return errs
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package hook
+
+import (
+  "testing"
+  "io/ioutil"
+  "os"
+  "path"
+  "strings"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+func TestRunAll(t *testing.T) {
+  directory, err := ioutil.TempDir(``, `gitcd-hook`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(directory)
+
+  repo := repository.Repository{Owner: `coollog`, Name: `gitcd`}
+  errs := RunAll(directory, repo, []string{`echo "$GITCD_REPOSITORY" > hooked`, `exit 3`, `echo again >> hooked`})
+  if len(errs) != 1 || !strings.Contains(errs[0].Error(), "`exit 3`") {
+    t.Errorf("RunAll expected one error for `exit 3` but got `%v`", errs)
+  }
+
+  hooked, err := ioutil.ReadFile(path.Join(directory, `hooked`))
+  if err != nil {
+    t.Fatalf("RunAll did not run in the directory: %s", err.Error())
+  }
+  if expectedHooked := "github.com/coollog/gitcd\nagain\n"; string(hooked) != expectedHooked {
+    t.Errorf("RunAll expected output `%s` but got `%s`", expectedHooked, string(hooked))
+  }
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package hook
+
+import (
+  "errors"
+  "fmt"
+  "gopkg.in/yaml.v2"
+  "io/ioutil"
+  "os"
+  "path"
+)
+
+// Repositories can provide their own hooks in a hooks file at the top of the checkout. These only run once the user
+// trusts them, since anyone who can push to the repository can change them. See the trust package.
+
+/** Name of the hooks file that repositories provide. */
+const RepositoryHooksFilename = `.gitcd-hooks.yaml`
+
+/**
+ * Loads the hooks file in the checkout at directory. Also returns the contents of the hooks file, to check if it is
+ * trusted, or nil if there is no hooks file.
+ *
+ * Example:
+ *
+ * postClone:
+ * - make bootstrap
+ * onEnter:
+ * - git fetch --quiet
+ */
+func LoadRepositoryHooks(directory string) (Hooks, []byte, error) {
+  hooksFile := path.Join(directory, RepositoryHooksFilename)
+  if _, err := os.Stat(hooksFile); os.IsNotExist(err) {
+    return Hooks{}, nil, nil
+  }
+
+  hooksFileContents, err := ioutil.ReadFile(hooksFile)
+  if err != nil {
+    return Hooks{}, nil, err
+  }
+
+  hooks := Hooks{}
+  err = yaml.UnmarshalStrict(hooksFileContents, &hooks)
+  if err != nil {
+    return Hooks{}, nil, errors.New(fmt.Sprintf("Hooks file at `%s` is not valid: %s", hooksFile, err.Error()))
+  }
+  return hooks, hooksFileContents, nil
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package registry
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/url"
+  "sort"
+  "strings"
+  "github.com/coollog/gitcd/cmd/gitcd/goimport"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+// Package registries, like npm, name packages that live in repositories. The repository is found in the metadata that
+// the registry serves for the package, like the `repository` field of an npm package.
+
+/** The registries that packages can be named with, like `npm` in `npm:express`, and their default base URLs. */
+var DefaultBaseUrls = map[string]string{
+  `npm`:    `https://registry.npmjs.org`,
+  `pypi`:   `https://pypi.org`,
+  `crates`: `https://crates.io`,
+  // Go import paths resolve with `go-import` meta tags served at the import path itself, so there is no base URL.
+  `go`: ``,
+}
+
+/** Resolves package names in registries to repositories. */
+type Resolver struct {
+  // Fetches the package metadata. Defaults to goimport.HttpFetch, asking for JSON.
+  Fetch goimport.Fetcher
+  // Maps from registry names to base URLs, like for a local registry mirror. These override the DefaultBaseUrls.
+  BaseUrls map[string]string
+}
+
+/** Splits a repositoryString like `npm:express` into its registry name and package name. Returns false if it is not a package. */
+func SplitPackage(repositoryString string) (string, string, bool) {
+  parts := strings.SplitN(repositoryString, `:`, 2)
+  if len(parts) != 2 || len(parts[1]) == 0 {
+    return ``, ``, false
+  }
+  if _, ok := DefaultBaseUrls[parts[0]]; !ok {
+    return ``, ``, false
+  }
+  return parts[0], parts[1], true
+}
+
+/** Checks if the repositoryString names a package in a registry, like `npm:express`. */
+func IsPackage(repositoryString string) bool {
+  _, _, ok := SplitPackage(repositoryString)
+  return ok
+}
+
+/**
+ * Resolves the package named by the repositoryString, like `npm:express`, to a repository.Target for its repository.
+ * The repository URL from the registry parses with the parser like any other repository string.
+ */
+func (r *Resolver) Resolve(parser *repository.Parser, repositoryString string) (repository.Target, error) {
+  registryName, packageName, ok := SplitPackage(repositoryString)
+  if !ok {
+    return repository.Target{}, errors.New(fmt.Sprintf("`%s` is not a package in a known registry", repositoryString))
+  }
+
+  if registryName == `go` {
+    return (&goimport.Resolver{Fetch: r.Fetch}).Resolve(parser, packageName)
+  }
+
+  var repositoryUrls []string
+  var directory string
+  var err error
+  switch registryName {
+  case `npm`:
+    repositoryUrls, directory, err = r.findNpmRepositoryUrls(packageName)
+  case `pypi`:
+    repositoryUrls, err = r.findPypiRepositoryUrls(parser, packageName)
+  case `crates`:
+    repositoryUrls, err = r.findCratesRepositoryUrls(packageName)
+  }
+  if err != nil {
+    return repository.Target{}, err
+  }
+
+  for _, repositoryUrl := range repositoryUrls {
+    if target, err := parser.Parse(NormalizeRepositoryUrl(repositoryUrl)); err == nil {
+      // Packages in monorepos are in a directory of the repository.
+      if len(target.Path) == 0 {
+        target.Path = strings.Trim(directory, `/`)
+      }
+      return target, nil
+    }
+  }
+  return repository.Target{}, errors.New(fmt.Sprintf("No repository found for %s package `%s`", registryName, packageName))
+}
+
+/**
+ * Normalizes the repository URLs that registries serve into repository strings. For example:
+ *   git+https://github.com/expressjs/express.git -> https://github.com/expressjs/express.git
+ *   github:expressjs/express -> https://github.com/expressjs/express
+ *   expressjs/express -> https://github.com/expressjs/express
+ */
+func NormalizeRepositoryUrl(repositoryUrl string) string {
+  repositoryUrl = strings.TrimPrefix(strings.TrimSpace(repositoryUrl), `git+`)
+
+  for shorthand, hostUrl := range map[string]string{
+    `github:`:    `https://github.com/`,
+    `gitlab:`:    `https://gitlab.com/`,
+    `bitbucket:`: `https://bitbucket.org/`,
+  } {
+    if strings.HasPrefix(repositoryUrl, shorthand) {
+      return hostUrl + strings.TrimPrefix(repositoryUrl, shorthand)
+    }
+  }
+
+  // npm treats `owner/name` as a repository on GitHub.
+  if !strings.Contains(repositoryUrl, `:`) && strings.Count(repositoryUrl, `/`) == 1 {
+    return `https://github.com/` + repositoryUrl
+  }
+  return repositoryUrl
+}
+
+/** The package metadata from `GET <base>/<package>`. The repository is a URL or an object with a URL. */
+type npmPackage struct {
+  Repository json.RawMessage `json:"repository"`
+}
+
+/**
+ * Finds the repository URL of the npm package, and the directory of the package in the repository if it is in a
+ * monorepo. Scoped packages, like `@babel/core`, work too.
+ */
+func (r *Resolver) findNpmRepositoryUrls(packageName string) ([]string, string, error) {
+  var metadata npmPackage
+  err := r.fetchJson(r.baseUrl(`npm`)+`/`+strings.Replace(url.PathEscape(packageName), `%40`, `@`, 1), &metadata)
+  if err != nil {
+    return nil, ``, err
+  }
+
+  var repositoryUrl string
+  if err := json.Unmarshal(metadata.Repository, &repositoryUrl); err == nil {
+    return []string{repositoryUrl}, ``, nil
+  }
+  var repositoryObject struct {
+    Url       string `json:"url"`
+    Directory string `json:"directory"`
+  }
+  if err := json.Unmarshal(metadata.Repository, &repositoryObject); err == nil && len(repositoryObject.Url) > 0 {
+    return []string{repositoryObject.Url}, repositoryObject.Directory, nil
+  }
+  return nil, ``, nil
+}
+
+/** The package metadata from `GET <base>/pypi/<package>/json`. */
+type pypiPackage struct {
+  Info struct {
+    HomePage    string            `json:"home_page"`
+    ProjectUrls map[string]string `json:"project_urls"`
+  } `json:"info"`
+}
+
+/**
+ * Finds the repository URLs of the PyPI package. Project URLs labeled like source code come first. Other project URLs
+ * and the home page only count if they are on known hosts, since they are often documentation sites.
+ */
+func (r *Resolver) findPypiRepositoryUrls(parser *repository.Parser, packageName string) ([]string, error) {
+  var metadata pypiPackage
+  err := r.fetchJson(r.baseUrl(`pypi`)+`/pypi/`+url.PathEscape(packageName)+`/json`, &metadata)
+  if err != nil {
+    return nil, err
+  }
+
+  var labels []string
+  for label := range metadata.Info.ProjectUrls {
+    labels = append(labels, label)
+  }
+  sort.Strings(labels)
+
+  var sourceUrls, otherUrls []string
+  for _, label := range labels {
+    projectUrl := metadata.Info.ProjectUrls[label]
+    label = strings.ToLower(label)
+    if strings.Contains(label, `source`) || strings.Contains(label, `repository`) || strings.Contains(label, `code`) {
+      sourceUrls = append(sourceUrls, projectUrl)
+    } else {
+      otherUrls = append(otherUrls, projectUrl)
+    }
+  }
+  otherUrls = append([]string{metadata.Info.HomePage}, otherUrls...)
+
+  for _, otherUrl := range otherUrls {
+    if target, err := parser.Parse(otherUrl); err == nil && parser.IsKnownHost(target.Repository.Host) {
+      sourceUrls = append(sourceUrls, otherUrl)
+    }
+  }
+  return sourceUrls, nil
+}
+
+/** The crate metadata from `GET <base>/api/v1/crates/<crate>`. */
+type cratesPackage struct {
+  Crate struct {
+    Repository string `json:"repository"`
+  } `json:"crate"`
+}
+
+/** Finds the repository URL of the crate. */
+func (r *Resolver) findCratesRepositoryUrls(packageName string) ([]string, error) {
+  var metadata cratesPackage
+  err := r.fetchJson(r.baseUrl(`crates`)+`/api/v1/crates/`+url.PathEscape(packageName), &metadata)
+  if err != nil {
+    return nil, err
+  }
+  if len(metadata.Crate.Repository) == 0 {
+    return nil, nil
+  }
+  return []string{metadata.Crate.Repository}, nil
+}
+
+/** Gets the base URL of the registry, without a trailing slash. */
+func (r *Resolver) baseUrl(registryName string) string {
+  if baseUrl, ok := r.BaseUrls[registryName]; ok {
+    return strings.TrimSuffix(baseUrl, `/`)
+  }
+  return DefaultBaseUrls[registryName]
+}
+
+/** Fetches the JSON at the metadataUrl into value. */
+func (r *Resolver) fetchJson(metadataUrl string, value interface{}) error {
+  fetch := r.Fetch
+  if fetch == nil {
+    fetch = func(url string) ([]byte, error) {
+      return goimport.HttpFetch(url, map[string]string{`Accept`: `application/json`})
+    }
+  }
+
+  contents, err := fetch(metadataUrl)
+  if err != nil {
+    return err
+  }
+  if err := json.Unmarshal(contents, value); err != nil {
+    return errors.New(fmt.Sprintf("Metadata at `%s` is not valid: %s", metadataUrl, err.Error()))
+  }
+  return nil
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package registry
+
+import (
+  "testing"
+  "fmt"
+  "net/http"
+  "net/http/httptest"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+/** Serves package metadata like registry.npmjs.org, pypi.org, and crates.io do. */
+func newRegistryServer() *httptest.Server {
+  metadata := map[string]string{
+    `/npm/express`:                `{"repository": {"type": "git", "url": "git+https://github.com/expressjs/express.git"}}`,
+    `/npm/@babel/core`:            `{"repository": {"type": "git", "url": "https://github.com/babel/babel.git", "directory": "packages/babel-core"}}`,
+    `/npm/left-pad`:               `{"repository": "stevemao/left-pad"}`,
+    `/npm/no-repository`:          `{"name": "no-repository"}`,
+    `/pypi/pypi/requests/json`:    `{"info": {"home_page": "https://requests.readthedocs.io", "project_urls": {"Documentation": "https://requests.readthedocs.io", "Source": "https://github.com/psf/requests"}}}`,
+    `/pypi/pypi/homepage/json`:    `{"info": {"home_page": "https://gitlab.com/group/homepage", "project_urls": {"Documentation": "https://docs.example.com/homepage"}}}`,
+    `/crates/api/v1/crates/serde`: `{"crate": {"repository": "https://github.com/serde-rs/serde"}}`,
+  }
+
+  return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    contents, ok := metadata[r.URL.Path]
+    if !ok {
+      http.NotFound(w, r)
+      return
+    }
+    fmt.Fprint(w, contents)
+  }))
+}
+
+func TestResolve(t *testing.T) {
+  server := newRegistryServer()
+  defer server.Close()
+
+  resolver := Resolver{BaseUrls: map[string]string{
+    `npm`:    server.URL + `/npm/`,
+    `pypi`:   server.URL + `/pypi`,
+    `crates`: server.URL + `/crates`,
+  }}
+
+  expectedTargets := []struct {
+    repositoryString string
+    expectedTarget repository.Target
+  }{
+    {"npm:express", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "expressjs", Name: "express"},
+      CloneUrl: "https://github.com/expressjs/express.git",
+    }},
+    {"npm:@babel/core", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "babel", Name: "babel"},
+      CloneUrl: "https://github.com/babel/babel.git",
+      Path: "packages/babel-core",
+    }},
+    {"npm:left-pad", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "stevemao", Name: "left-pad"},
+      CloneUrl: "https://github.com/stevemao/left-pad",
+    }},
+    {"pypi:requests", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "psf", Name: "requests"},
+      CloneUrl: "https://github.com/psf/requests",
+    }},
+    {"pypi:homepage", repository.Target{
+      Repository: repository.Repository{Host: "gitlab.com", Owner: "group", Name: "homepage"},
+      CloneUrl: "https://gitlab.com/group/homepage",
+    }},
+    {"crates:serde", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "serde-rs", Name: "serde"},
+      CloneUrl: "https://github.com/serde-rs/serde",
+    }},
+    {"go:github.com/coollog/gitcd/cmd/gitcd", repository.Target{
+      Repository: repository.Repository{Host: "github.com", Owner: "coollog", Name: "gitcd"},
+      Path: "cmd/gitcd",
+    }},
+  }
+
+  for _, expectedTarget := range expectedTargets {
+    target, err := resolver.Resolve(&repository.Parser{}, expectedTarget.repositoryString)
+    if err != nil {
+      t.Errorf("Resolve package `%s` errored: %s", expectedTarget.repositoryString, err.Error())
+      continue
+    }
+    if target != expectedTarget.expectedTarget {
+      t.Errorf("Resolve package `%s` expected `%#v` but got `%#v`", expectedTarget.repositoryString, expectedTarget.expectedTarget, target)
+    }
+  }
+
+  for _, repositoryString := range []string{"npm:no-repository", "npm:missing", "coollog/gitcd"} {
+    if _, err := resolver.Resolve(&repository.Parser{}, repositoryString); err == nil {
+      t.Errorf("Resolve package `%s` should have errored", repositoryString)
+    }
+  }
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "io/ioutil"
+  "os"
+  "path"
+  "strings"
+)
+
+/**
+ * Finds the clones of the repo under gitcdHome that differ from it only in case, on hosts that ignore case. Returns
+ * their directories relative to gitcdHome. More than one means that the same repository was cloned more than once.
+ */
+func FindCaseDuplicates(gitcdHome string, repo Repository) []string {
+  if !FindForge(repo.HostOrDefault()).CaseInsensitive {
+    return nil
+  }
+  return findCaseVariants(gitcdHome, ``, strings.Split(repo.Path(), `/`))
+}
+
+/** Finds the directories under gitcdHome/directory that match the parts of a path, ignoring case. */
+func findCaseVariants(gitcdHome string, directory string, parts []string) []string {
+  if len(parts) == 0 {
+    return []string{directory}
+  }
+
+  fileInfos, err := ioutil.ReadDir(path.Join(gitcdHome, directory))
+  if err != nil {
+    return nil
+  }
+
+  var variants []string
+  for _, fileInfo := range fileInfos {
+    if fileInfo.Mode().IsDir() && strings.EqualFold(fileInfo.Name(), parts[0]) {
+      variants = append(variants, findCaseVariants(gitcdHome, path.Join(directory, fileInfo.Name()), parts[1:])...)
+    }
+  }
+  return variants
+}
+
+/** Gets the repo with the case of its existing clone, if it has one. Prefers a clone with the same case as the repo. */
+func findClonedCase(gitcdHome string, repo Repository) Repository {
+  if _, err := os.Stat(path.Join(gitcdHome, repo.Path())); err == nil {
+    return repo
+  }
+
+  variants := FindCaseDuplicates(gitcdHome, repo)
+  if len(variants) == 0 {
+    return repo
+  }
+
+  // Takes the owner and name from the end of the clone directory, since the host directory is always lowercase.
+  parts := strings.Split(variants[0], `/`)
+  ownerDepth := len(strings.Split(repo.Owner, `/`))
+  repo.Owner = strings.Join(parts[len(parts)-1-ownerDepth:len(parts)-1], `/`)
+  repo.Name = parts[len(parts)-1]
+  return repo
+}
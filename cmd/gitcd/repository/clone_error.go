@@ -0,0 +1,46 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "fmt"
+  "strings"
+)
+
+/** One URL that Clone tried, and why it failed. */
+type CloneAttempt struct {
+  Url string
+  // The last line that git printed to stderr, like `fatal: repository 'https://...' not found`, or the error itself.
+  Reason string
+}
+
+/** The error that Clone returns when every URL failed. */
+type CloneError struct {
+  Attempts []CloneAttempt
+}
+
+func (e *CloneError) Error() string {
+  if len(e.Attempts) == 0 {
+    return `no URLs to clone with`
+  }
+
+  lines := []string{fmt.Sprintf("tried %d URL(s):", len(e.Attempts))}
+  for i, attempt := range e.Attempts {
+    lines = append(lines, fmt.Sprintf("  %d) %s: %s", i+1, attempt.Url, attempt.Reason))
+  }
+  return strings.Join(lines, "\n")
+}
@@ -20,28 +20,147 @@ import (
   "os"
   "path"
   "os/exec"
-  "fmt"
   "log"
+  "bytes"
+  "io"
+  "strings"
 )
 
-func Clone(gitcdHome string, repositoryString string, repository Repository) error {
-  // Makes all the directories up to the owner directory.
-  ownerDirectory := path.Join(gitcdHome, repository.Owner)
-  err := os.MkdirAll(ownerDirectory, 0755)
+/** Options for how to clone. */
+type CloneOptions struct {
+  // The rewrites apply to the URLs to clone with, and push rewrites set the push URL of the clone.
+  Rewrites Rewrites
+  // The URLs to try after the URL that the repository string was, if any. Defaults to the HTTPS URL of the repository.
+  UrlTemplates CloneUrlTemplates
+  // Makes a shallow clone with this many commits, if more than 0.
+  Depth int
+  // Makes a partial clone with this filter, like `blob:none`, if not empty.
+  Filter string
+  // Makes a sparse checkout with only the top-level files, and the directories at the SparsePaths.
+  Sparse      bool
+  SparsePaths []string
+  // Borrows objects from a mirror of the repository under this directory, making the mirror if needed. Optional.
+  MirrorRoot string
+  // Initializes the submodules recursively, fetching this many submodules at once if more than 0, and makes shallow
+  // clones of the submodules if ShallowSubmodules.
+  Submodules        bool
+  SubmoduleJobs     int
+  ShallowSubmodules bool
+}
+
+/**
+ * Clones the repository into its directory under gitcdHome. Tries cloneUrl first, if not empty, and then the URLs
+ * from the UrlTemplates in order. Returns a *CloneError with every attempt if all of them fail.
+ *
+ * The clone is staged under the gitcd home and only moves into the directory of the repository once it completes, so
+ * failed or interrupted clones never look like existing repositories.
+ */
+func Clone(gitcdHome string, cloneUrl string, repository Repository, options CloneOptions) error {
+  return CloneInto(gitcdHome, Resolve(gitcdHome, repository).Directory, cloneUrl, repository, options)
+}
+
+/** Clones the repository like Clone, but into the directory, like for a fork that goes in the directory of its upstream. */
+func CloneInto(gitcdHome string, directory string, cloneUrl string, repository Repository, options CloneOptions) error {
+  // Cleans up after crashed runs before staging this clone.
+  if err := RemoveStaleStagingDirectories(gitcdHome); err != nil {
+    log.Printf("Could not remove stale staging directories: %s\n", err.Error())
+  }
+  stagingDirectory, err := newStagingDirectory(gitcdHome)
+  if err != nil {
+    return err
+  }
+  defer os.RemoveAll(stagingDirectory)
+  stopRemovingOnInterrupt := removeOnInterrupt(stagingDirectory)
+  defer stopRemovingOnInterrupt()
+
+  err = cloneUrls(stagingDirectory, cloneUrl, repository, options)
+  if err != nil {
+    return err
+  }
+
+  // Makes all the directories up to the owner directory, and moves the completed clone there.
+  err = os.MkdirAll(path.Dir(directory), 0755)
   if err != nil {
     return err
   }
+  return os.Rename(path.Join(stagingDirectory, repository.Name), directory)
+}
+
+/** Clones the repository into the stagingDirectory, trying cloneUrl first and then the URLs from the templates. */
+func cloneUrls(stagingDirectory string, cloneUrl string, repository Repository, options CloneOptions) error {
+  stagedDirectory := path.Join(stagingDirectory, repository.Name)
+
+  var urls []string
+  if len(cloneUrl) > 0 {
+    urls = append(urls, cloneUrl)
+  }
+  for _, url := range options.UrlTemplates.Urls(repository) {
+    if url != cloneUrl {
+      urls = append(urls, url)
+    }
+  }
+
+  cloneError := &CloneError{}
+  for i, url := range urls {
+    if i > 0 {
+      log.Printf("Cloning repository `%s` failed, trying again with `%s`...\n", RedactUrl(options.Rewrites.Apply(urls[i-1])), RedactUrl(options.Rewrites.Apply(url)))
+    }
+
+    var reference string
+    if len(options.MirrorRoot) > 0 {
+      reference = ensureMirror(options.MirrorRoot, repository, url, options.Rewrites)
+    }
+
+    reason, err := cloneFrom(stagingDirectory, url, repository.Name, reference, options)
+    if err == nil {
+      return nil
+    }
+    // Attempts end up in logs, so they do not show passwords.
+    appliedUrl := options.Rewrites.Apply(url)
+    reason = strings.Replace(reason, appliedUrl, RedactUrl(appliedUrl), -1)
+    cloneError.Attempts = append(cloneError.Attempts, CloneAttempt{RedactUrl(appliedUrl), reason})
+
+    // Removes what the failed clone left behind.
+    if err := os.RemoveAll(stagedDirectory); err != nil {
+      return err
+    }
+  }
+  return cloneError
+}
+
+/**
+ * Clones the url into the directory name under parentDirectory, sets the push URL from the push rewrites, and sets the
+ * sparse paths. Borrows objects from the reference repository, if not empty. Returns the reason that cloning failed,
+ * if it did.
+ */
+func cloneFrom(parentDirectory string, url string, name string, reference string, options CloneOptions) (string, error) {
+  args := append([]string{"-C", parentDirectory, "clone"}, options.cloneArgs()...)
+  if len(reference) > 0 {
+    args = append(args, "--reference-if-able", reference)
+  }
+  args = append(args, "--", options.Rewrites.Apply(url), name)
+  reason, err := commandWithReason(exec.Command("git", args...))
+  if err != nil {
+    return reason, err
+  }
 
-  // Tries to clone the original repositoryString first.
-  err = command(exec.Command("git", "-C", ownerDirectory, "clone", repositoryString))
+  directory := path.Join(parentDirectory, name)
+  err = setPushUrl(directory, options.Rewrites.ApplyPush(url))
   if err == nil {
-    return nil
+    err = options.setSparsePaths(directory)
+  }
+  if err != nil {
+    return err.Error(), err
   }
+  return ``, nil
+}
 
-  // If that fails, then tries to construct a clone-able URL from repository.
-  repositoryUrl := fmt.Sprintf("https://github.com/%s/%s", repository.Owner, repository.Name)
-  log.Printf("Cloning repository `%s` failed, trying again with `%s`...\n", repositoryString, repositoryUrl)
-  return command(exec.Command("git", "-C", ownerDirectory, "clone", repositoryUrl))
+/** Sets the push URL of origin for the clone at directory, if pushUrl is not empty. */
+func setPushUrl(directory string, pushUrl string) error {
+  if len(pushUrl) == 0 {
+    return nil
+  }
+  return command(exec.Command("git", "-C", directory, "config", "remote.origin.pushurl", pushUrl))
 }
 
 func command(cmd *exec.Cmd) error {
@@ -49,3 +168,27 @@ func command(cmd *exec.Cmd) error {
   cmd.Stderr = os.Stderr
   return cmd.Run()
 }
+
+/** Runs the cmd like command, but prints its stdout to stderr too, since gcd reads the directory to go to from stdout. */
+func stderrCommand(cmd *exec.Cmd) error {
+  cmd.Stdout = os.Stderr
+  cmd.Stderr = os.Stderr
+  return cmd.Run()
+}
+
+/** Runs the cmd like command, and returns the last line it printed to stderr (or the error) as the reason it failed. */
+func commandWithReason(cmd *exec.Cmd) (string, error) {
+  var stderr bytes.Buffer
+  cmd.Stdout = os.Stdout
+  cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+  err := cmd.Run()
+  if err == nil {
+    return ``, nil
+  }
+
+  lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+  if reason := strings.TrimSpace(lines[len(lines)-1]); len(reason) > 0 {
+    return reason, err
+  }
+  return err.Error(), err
+}
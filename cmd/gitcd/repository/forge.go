@@ -0,0 +1,239 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "regexp"
+  "strings"
+  "net/url"
+)
+
+/** The naming rules of a git host. */
+type Forge struct {
+  // Matches each part of a valid owner.
+  OwnerPart *regexp.Regexp
+  // Matches a valid repository name.
+  NamePart *regexp.Regexp
+  // Whether the owner can be a nested namespace, like `group/subgroup`.
+  Nested bool
+  // Whether the host ignores case in owners and names.
+  CaseInsensitive bool
+  // Whether repositories have no owner, like on AWS CodeCommit where the host is the namespace.
+  Ownerless bool
+  // The host that identifies repositories on the forge, if URLs use other hosts too, like `ssh.dev.azure.com`.
+  Host string
+  // Converts repository paths in the URL shapes of the host into `owner/name`. Returns false if the repository path
+  // is not in any of the shapes. Optional.
+  ParsePath func(repositoryPath string) (string, bool)
+  // Converts the owner and name into the repository path of clone-able URLs. Optional; defaults to `owner/name`.
+  FormatPath func(owner string, name string) string
+}
+
+/** GitHub owners are letters, digits, underscores, and dashes. Repository names may also contain dots. */
+var GitHub = Forge{
+  OwnerPart:       regexp.MustCompile(`^\w[\w-]{0,38}$`),
+  NamePart:        regexp.MustCompile(`^[\w.-]{1,100}$`),
+  CaseInsensitive: true,
+}
+
+/** GitLab namespaces can be nested. Paths cannot start with a dash or a dot, or end with a dot. */
+var GitLab = Forge{
+  OwnerPart:       regexp.MustCompile(`^\w([\w.-]*[\w-])?$`),
+  NamePart:        regexp.MustCompile(`^\w([\w.-]*[\w-])?$`),
+  Nested:          true,
+  CaseInsensitive: true,
+}
+
+/** Bitbucket Cloud workspaces and repository names are letters, digits, underscores, dashes, and dots. */
+var Bitbucket = Forge{
+  OwnerPart:       regexp.MustCompile(`^[\w.-]+$`),
+  NamePart:        regexp.MustCompile(`^[\w.-]+$`),
+  CaseInsensitive: true,
+}
+
+/**
+ * Azure DevOps repositories are under an organization and a project, like `org/project/_git/name` in HTTPS URLs and
+ * `v3/org/project/name` in SSH URLs on `ssh.dev.azure.com`. The owner is `org/project`.
+ */
+var AzureDevOps = Forge{
+  OwnerPart:       regexp.MustCompile(`^[\w.-]+( [\w.-]+)*$`),
+  NamePart:        regexp.MustCompile(`^[\w.-]+$`),
+  Nested:          true,
+  CaseInsensitive: true,
+  Host:            `dev.azure.com`,
+  ParsePath: func(repositoryPath string) (string, bool) {
+    parts := strings.Split(repositoryPath, `/`)
+    switch {
+    case len(parts) == 4 && parts[2] == `_git`:
+      return strings.Join([]string{parts[0], parts[1], parts[3]}, `/`), true
+    case len(parts) == 4 && parts[0] == `v3`:
+      return strings.Join(parts[1:], `/`), true
+    case len(parts) == 3:
+      return repositoryPath, true
+    }
+    return ``, false
+  },
+  FormatPath: func(owner string, name string) string {
+    ownerParts := strings.Split(owner, `/`)
+    for i, ownerPart := range ownerParts {
+      ownerParts[i] = url.PathEscape(ownerPart)
+    }
+    return strings.Join(ownerParts, `/`) + `/_git/` + url.PathEscape(name)
+  },
+}
+
+/** AWS CodeCommit repositories are at `v1/repos/name` on a host for each region. The host is the namespace. */
+var CodeCommit = Forge{
+  NamePart:  regexp.MustCompile(`^[\w.-]{1,100}$`),
+  Ownerless: true,
+  ParsePath: func(repositoryPath string) (string, bool) {
+    parts := strings.Split(repositoryPath, `/`)
+    switch {
+    case len(parts) == 3 && parts[0] == `v1` && parts[1] == `repos`:
+      return parts[2], true
+    case len(parts) == 1:
+      return repositoryPath, true
+    }
+    return ``, false
+  },
+  FormatPath: func(owner string, name string) string {
+    return `v1/repos/` + name
+  },
+}
+
+/**
+ * Bitbucket Server (and Data Center) repositories are under a project, like `scm/PROJ/name` in HTTPS URLs,
+ * `PROJ/name` in SSH URLs, and `projects/PROJ/repos/name/browse` in web URLs.
+ */
+var BitbucketServer = Forge{
+  OwnerPart:       regexp.MustCompile(`^[\w.-]+$`),
+  NamePart:        regexp.MustCompile(`^[\w.-]+$`),
+  CaseInsensitive: true,
+  ParsePath: func(repositoryPath string) (string, bool) {
+    parts := strings.Split(repositoryPath, `/`)
+    switch {
+    case len(parts) == 3 && parts[0] == `scm`:
+      return strings.Join(parts[1:], `/`), true
+    case len(parts) >= 4 && parts[0] == `projects` && parts[2] == `repos`:
+      return parts[1] + `/` + parts[3], true
+    case len(parts) == 2:
+      return repositoryPath, true
+    }
+    return ``, false
+  },
+  FormatPath: func(owner string, name string) string {
+    return `scm/` + owner + `/` + name + `.git`
+  },
+}
+
+/**
+ * SourceHut owners are users with a `~`, like `~user/name`. Repository strings without the `~`, like `user/name`, are
+ * for the same repository.
+ */
+var SourceHut = Forge{
+  OwnerPart: regexp.MustCompile(`^~[a-z_][\w-]*$`),
+  NamePart:  regexp.MustCompile(`^[\w.-]+$`),
+  ParsePath: func(repositoryPath string) (string, bool) {
+    if strings.HasPrefix(repositoryPath, `~`) {
+      return repositoryPath, true
+    }
+    return `~` + repositoryPath, true
+  },
+}
+
+/** Gitea and Forgejo (like Codeberg) owners and repository names are letters, digits, underscores, dashes, and dots. */
+var Gitea = Forge{
+  OwnerPart:       regexp.MustCompile(`^[\w.-]+$`),
+  NamePart:        regexp.MustCompile(`^[\w.-]+$`),
+  CaseInsensitive: true,
+}
+
+/**
+ * Rules for hosts without known rules. These are permissive so that self-hosted instances work, and case-sensitive
+ * since some git servers are.
+ */
+var Generic = Forge{
+  OwnerPart: regexp.MustCompile(`^[\w.-]+$`),
+  NamePart:  regexp.MustCompile(`^[\w.-]+$`),
+  Nested:    true,
+}
+
+/** Maps from known hosts to their rules. */
+var Forges = map[string]Forge{
+  `github.com`:        GitHub,
+  `gitlab.com`:        GitLab,
+  `bitbucket.org`:     Bitbucket,
+  `dev.azure.com`:     AzureDevOps,
+  `ssh.dev.azure.com`: AzureDevOps,
+  `git.sr.ht`:         SourceHut,
+  `codeberg.org`:      Gitea,
+  `gitea.com`:         Gitea,
+}
+
+/** Maps from the names of forges, like in the gitcd config, to their rules. */
+var ForgeNames = map[string]Forge{
+  `github`:           GitHub,
+  `gitlab`:           GitLab,
+  `bitbucket`:        Bitbucket,
+  `bitbucket-server`: BitbucketServer,
+  `azure-devops`:     AzureDevOps,
+  `sourcehut`:        SourceHut,
+  `gitea`:            Gitea,
+  `forgejo`:          Gitea,
+  `generic`:          Generic,
+}
+
+/** Rules for hosts matching a pattern, for forges that have many hosts. */
+type HostForge struct {
+  HostRegex *regexp.Regexp
+  Forge     Forge
+}
+
+/** Known hosts by pattern. These apply to hosts that are not in Forges, in order. */
+var HostForges = []HostForge{
+  {regexp.MustCompile(`^git-codecommit(-fips)?\.[a-z0-9-]+\.amazonaws\.com$`), CodeCommit},
+  {regexp.MustCompile(`^bitbucket\.`), BitbucketServer},
+}
+
+/** Finds the rules for the host, defaulting to Generic. */
+func FindForge(host string) Forge {
+  if forge, ok := findKnownForge(host); ok {
+    return forge
+  }
+  return Generic
+}
+
+/** Finds the rules for the host in Forges and HostForges. Returns false if the host is not known. */
+func findKnownForge(host string) (Forge, bool) {
+  if forge, ok := Forges[host]; ok {
+    return forge, true
+  }
+  for _, hostForge := range HostForges {
+    if hostForge.HostRegex.MatchString(host) {
+      return hostForge.Forge, true
+    }
+  }
+  return Forge{}, false
+}
+
+/** Gets the repository path of clone-able URLs for the owner and name. */
+func (f Forge) formatPath(owner string, name string) string {
+  if f.FormatPath == nil {
+    return owner + `/` + name
+  }
+  return f.FormatPath(owner, name)
+}
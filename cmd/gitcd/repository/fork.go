@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "os/exec"
+  "strings"
+)
+
+/** The remote of forks that points at the repository they are forked from. */
+const UpstreamRemote = `upstream`
+
+/** Makes a repository like the upstream but owned by the forkOwner, like `myuser/repo` for `upstream-org/repo`. */
+func ForkOf(upstream Repository, forkOwner string) Repository {
+  return Repository{upstream.Host, forkOwner, upstream.Name}
+}
+
+/**
+ * Converts the cloneUrl of the upstream into the clone URL of the fork, keeping the protocol, like
+ * `git@github.com:myuser/repo.git` for `git@github.com:upstream-org/repo.git`. Returns empty if the cloneUrl does not
+ * contain the owner and name of the upstream.
+ */
+func ForkUrl(cloneUrl string, upstream Repository, fork Repository) string {
+  upstreamPath := upstream.Owner + `/` + upstream.Name
+  if !strings.Contains(cloneUrl, upstreamPath) {
+    return ``
+  }
+  return strings.Replace(cloneUrl, upstreamPath, fork.Owner+`/`+fork.Name, 1)
+}
+
+/**
+ * Adds the upstream remote at upstreamUrl to the clone of a fork at directory, fetches it, and sets the current branch
+ * (the default branch of a new clone) to track the default branch of the upstream.
+ */
+func AddUpstream(directory string, upstreamUrl string) error {
+  for _, args := range [][]string{
+    {"remote", "add", UpstreamRemote, upstreamUrl},
+    {"fetch", UpstreamRemote},
+    {"remote", "set-head", UpstreamRemote, "--auto"},
+  } {
+    if err := command(exec.Command("git", append([]string{"-C", directory}, args...)...)); err != nil {
+      return err
+    }
+  }
+
+  upstreamBranch, err := exec.Command("git", "-C", directory, "symbolic-ref", "--short", "refs/remotes/"+UpstreamRemote+"/HEAD").Output()
+  if err != nil {
+    return err
+  }
+  return command(exec.Command("git", "-C", directory, "branch", "--set-upstream-to", strings.TrimSpace(string(upstreamBranch))))
+}
+
+/** Checks if the clone at directory has the upstream remote, like clones of forks. */
+func HasUpstream(directory string) bool {
+  return exec.Command("git", "-C", directory, "remote", "get-url", UpstreamRemote).Run() == nil
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "errors"
+  "fmt"
+  "os"
+  "os/exec"
+  "path"
+  "path/filepath"
+  "strings"
+)
+
+/**
+ * The host of repositories cloned from local paths. These live directly under the gitcd home, like repositories on the
+ * DefaultHost. Parsed hosts always have a dot, so no parsed host is the LocalHost.
+ */
+const LocalHost = `file`
+
+/** Checks if the repositoryString is a local path, like `/srv/git/owner/name.git`, or a `file://` URL. */
+func IsLocalPath(repositoryString string) bool {
+  for _, prefix := range []string{`file://`, `/`, `./`, `../`} {
+    if strings.HasPrefix(repositoryString, prefix) {
+      return true
+    }
+  }
+  return false
+}
+
+/**
+ * Parses the local repositoryString into a Target that clones from the local path.
+ *
+ * The repository is the last two path components of the local path, like `owner/name` for `/srv/git/owner/name.git`.
+ * If the local path is under a path in LocalPaths, then the repository is the rest of the local path under the
+ * repository prefix that the path maps to instead. For example, if `/srv/git` maps to `git.example.com`, then
+ * `/srv/git/group/sub/name.git` is the repository `git.example.com/group/sub/name`.
+ */
+func (p *Parser) parseLocal(repositoryString string) (Target, error) {
+  localPath := strings.TrimPrefix(repositoryString, `file://`)
+  localPath, err := filepath.Abs(localPath)
+  if err != nil {
+    return Target{}, &ParseError{repositoryString, InvalidFormat, repositoryString}
+  }
+  if _, err := os.Stat(localPath); err != nil {
+    return Target{}, &ParseError{repositoryString, NotFound, localPath}
+  }
+  localPath = filepath.ToSlash(localPath)
+
+  // Uses the directory containing the .git directory of non-bare repositories.
+  repositoryPath := strings.TrimSuffix(strings.TrimSuffix(localPath, `/.git`), `.git`)
+
+  cloneUrl := localPath
+  if strings.HasPrefix(repositoryString, `file://`) {
+    cloneUrl = `file://` + localPath
+  }
+
+  // Maps the local path with the longest matching mapping.
+  var longestLocalPath string
+  for mappedLocalPath := range p.LocalPaths {
+    mappedLocalPath = strings.TrimSuffix(filepath.ToSlash(mappedLocalPath), `/`)
+    if strings.HasPrefix(repositoryPath, mappedLocalPath+`/`) && len(mappedLocalPath) > len(longestLocalPath) {
+      longestLocalPath = mappedLocalPath
+    }
+  }
+  if len(longestLocalPath) > 0 {
+    mappedRepositoryString := path.Join(p.LocalPaths[longestLocalPath], strings.TrimPrefix(repositoryPath, longestLocalPath))
+    target, err := p.parseTarget(mappedRepositoryString)
+    if err != nil {
+      return Target{}, err
+    }
+    target.CloneUrl = cloneUrl
+    return target, nil
+  }
+
+  parts := strings.Split(repositoryPath, `/`)
+  if len(parts) < 3 {
+    return Target{}, &ParseError{repositoryString, InvalidFormat, localPath}
+  }
+  repository, err := parsePath(repositoryString, LocalHost, strings.Join(parts[len(parts)-2:], `/`))
+  if err != nil {
+    return Target{}, err
+  }
+  // Clones of local repositories live in `owner/name` like on the DefaultHost, where an owner with a dot is a host.
+  if strings.Contains(repository.Owner, `.`) {
+    return Target{}, &ParseError{repositoryString, InvalidOwner, repository.Owner}
+  }
+  return Target{Repository: repository, CloneUrl: cloneUrl}, nil
+}
+
+/**
+ * Checks that the existing clone at directory is a clone of the target, by parsing the URL of its origin. Local
+ * repositories live in the same directories as repositories on the DefaultHost, like `owner/name`, so a clone of one
+ * must not be taken for the other, nor for a local repository at another path.
+ */
+func (p *Parser) CheckClone(directory string, target Target) error {
+  output, err := exec.Command("git", "-C", directory, "config", "--get", "remote.origin.url").Output()
+  if err != nil {
+    return nil
+  }
+  originUrl := strings.TrimSpace(string(output))
+
+  sameRepository := true
+  if target.Repository.Host == LocalHost && IsLocalPath(originUrl) {
+    sameRepository = localPath(originUrl) == localPath(target.CloneUrl)
+  } else if origin, err := p.Parse(originUrl); err == nil {
+    // Forks are cloned into the directory of their upstream, so only local clones are told apart by their identity.
+    sameRepository = (origin.Repository.Host == LocalHost) == (target.Repository.Host == LocalHost)
+  }
+  if !sameRepository {
+    cloneUrl := target.CloneUrl
+    if len(cloneUrl) == 0 {
+      cloneUrl = target.Repository.Url()
+    }
+    return errors.New(fmt.Sprintf("`%s` is already a clone of `%s`, not `%s`; local repositories share directories with repositories on %s, so move the clone away to clone `%s`", directory, originUrl, cloneUrl, DefaultHost, cloneUrl))
+  }
+  return nil
+}
+
+/** Gets the cleaned local path of the local URL. */
+func localPath(localUrl string) string {
+  return filepath.Clean(strings.TrimSuffix(strings.TrimPrefix(localUrl, `file://`), `/`))
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package repository
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "log"
+  "os"
+  "path"
+  "path/filepath"
+  "strings"
+  "time"
+)
+
+/**
+ * The directory under the gitcd home with the locks of repositories being cloned. The locks are OS file locks on the
+ * files in the directory, so the OS releases the locks of processes that exit or crash. The files themselves stay, since
+ * removing a lock file while another process waits on it would let a third process lock a new file with the same name.
+ */
+const LockDirectoryName = `.gitcd-locks`
+
+/** How often to check if a lock held by another process is released. */
+const lockPollInterval = 200 * time.Millisecond
+
+/** A lock on the directory of a repository, held by this process. */
+type Lock struct {
+  file *os.File
+}
+
+/**
+ * Locks the directory of a repository under the gitcdHome, so that only one process clones into it at a time. Waits
+ * while another process holds the lock. Directories that differ only in case share a lock, since they are the same
+ * directory on some file systems.
+ */
+func LockDirectory(gitcdHome string, directory string) (*Lock, error) {
+  lockRoot := path.Join(gitcdHome, LockDirectoryName)
+  if err := os.MkdirAll(lockRoot, 0755); err != nil {
+    return nil, err
+  }
+  lockFile := path.Join(lockRoot, lockName(gitcdHome, directory))
+
+  waiting := false
+  for {
+    file, err := tryLock(lockFile)
+    if err != nil {
+      return nil, err
+    }
+    if file != nil {
+      return &Lock{file}, nil
+    }
+
+    if !waiting {
+      log.Printf("Waiting for another gitcd to finish cloning into `%s`...\n", directory)
+      waiting = true
+    }
+    time.Sleep(lockPollInterval)
+  }
+}
+
+/** Releases the lock. */
+func (l *Lock) Unlock() error {
+  return l.file.Close()
+}
+
+/** Gets the name of the lock file for the directory under the gitcdHome. */
+func lockName(gitcdHome string, directory string) string {
+  repositoryPath, err := filepath.Rel(gitcdHome, directory)
+  if err != nil {
+    repositoryPath = directory
+  }
+  hash := sha256.Sum256([]byte(strings.ToLower(filepath.ToSlash(repositoryPath))))
+  return hex.EncodeToString(hash[:]) + `.lock`
+}
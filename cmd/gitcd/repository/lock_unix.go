@@ -0,0 +1,44 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "os"
+  "syscall"
+)
+
+/** Opens the lockFile and locks it with flock without waiting. Returns nil if another process holds the lock. */
+func tryLock(lockFile string) (*os.File, error) {
+  file, err := os.OpenFile(lockFile, os.O_RDWR|os.O_CREATE, 0644)
+  if err != nil {
+    return nil, err
+  }
+
+  err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+  if err == syscall.EWOULDBLOCK {
+    file.Close()
+    return nil, nil
+  }
+  if err != nil {
+    file.Close()
+    return nil, err
+  }
+  return file, nil
+}
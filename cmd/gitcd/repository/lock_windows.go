@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "os"
+  "syscall"
+)
+
+/** The error for opening a file that another handle has open without sharing it. */
+const errorSharingViolation syscall.Errno = 32
+
+/**
+ * Opens the lockFile without sharing it, so that no other process can open it until this one closes it or exits.
+ * Returns nil if another process holds the lock.
+ */
+func tryLock(lockFile string) (*os.File, error) {
+  name, err := syscall.UTF16PtrFromString(lockFile)
+  if err != nil {
+    return nil, err
+  }
+
+  handle, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+  if err == errorSharingViolation {
+    return nil, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  return os.NewFile(uintptr(handle), lockFile), nil
+}
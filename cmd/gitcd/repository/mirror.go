@@ -0,0 +1,101 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "fmt"
+  "log"
+  "os"
+  "os/exec"
+  "path"
+  "path/filepath"
+  "strings"
+)
+
+// Mirrors are bare copies of repositories that clones borrow objects from with `git clone --reference-if-able`, so that
+// clones of the same repository in different gitcd homes share one object store. Mirrors never prune objects, since
+// clones that borrow from them might still need them.
+
+/** Gets the directory of the mirror of the repository under the mirrorRoot, like `github.com/owner/name.git`. */
+func MirrorDirectory(mirrorRoot string, repository Repository) string {
+  return path.Join(mirrorRoot, repository.HostOrDefault(), repository.Owner, repository.Name) + `.git`
+}
+
+/**
+ * Makes the mirror of the repository under the mirrorRoot from the url, if the mirror does not exist yet. Returns the
+ * mirror directory, or empty if there is no mirror. Failing to make the mirror does not fail the clone.
+ */
+func ensureMirror(mirrorRoot string, repository Repository, url string, rewrites Rewrites) string {
+  mirrorDirectory := MirrorDirectory(mirrorRoot, repository)
+  if _, err := os.Stat(mirrorDirectory); err == nil {
+    return mirrorDirectory
+  }
+
+  err := makeMirror(mirrorDirectory, rewrites.Apply(url))
+  if err != nil {
+    log.Printf("Could not make mirror `%s`: %s\n", mirrorDirectory, err.Error())
+    return ``
+  }
+  return mirrorDirectory
+}
+
+/** Mirrors the url into the mirrorDirectory. The mirror is made next to the mirrorDirectory and moved into place once it completes. */
+func makeMirror(mirrorDirectory string, url string) error {
+  err := os.MkdirAll(path.Dir(mirrorDirectory), 0755)
+  if err != nil {
+    return err
+  }
+  stagedDirectory := fmt.Sprintf(`%s.%d.tmp`, mirrorDirectory, os.Getpid())
+  defer os.RemoveAll(stagedDirectory)
+
+  err = command(exec.Command("git", "clone", "--mirror", "--", url, stagedDirectory))
+  if err != nil {
+    return err
+  }
+  err = command(exec.Command("git", "-C", stagedDirectory, "config", "gc.pruneExpire", "never"))
+  if err != nil {
+    return err
+  }
+  return os.Rename(stagedDirectory, mirrorDirectory)
+}
+
+/** Finds the mirrors under the mirrorRoot. */
+func FindMirrors(mirrorRoot string) ([]string, error) {
+  var mirrors []string
+  err := filepath.Walk(mirrorRoot, func(directory string, fileInfo os.FileInfo, err error) error {
+    if err != nil {
+      if os.IsNotExist(err) && directory == mirrorRoot {
+        return filepath.SkipDir
+      }
+      return err
+    }
+    if !fileInfo.IsDir() || !strings.HasSuffix(directory, `.git`) {
+      return nil
+    }
+    if _, err := os.Stat(path.Join(directory, `HEAD`)); err == nil {
+      mirrors = append(mirrors, directory)
+      return filepath.SkipDir
+    }
+    return nil
+  })
+  return mirrors, err
+}
+
+/** Fetches the latest refs into the mirror at mirrorDirectory. Refs deleted upstream stay in the mirror. */
+func UpdateMirror(mirrorDirectory string) error {
+  return command(exec.Command("git", "-C", mirrorDirectory, "remote", "update"))
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import "fmt"
+
+/** The kind of problem with a repository string. */
+type ParseErrorKind int
+
+const (
+  // The repository string is not shaped like a repository.
+  InvalidFormat ParseErrorKind = iota
+  UnsupportedProtocol
+  UnsupportedHost
+  InvalidOwner
+  InvalidName
+  // The repository string has more path segments than the host allows.
+  ExtraSegments
+  // The repository string is a local path that does not exist.
+  NotFound
+  // The ref to check out is not a valid ref name, like `-x` or `a..b`.
+  InvalidRef
+)
+
+/** Describes which part of a repository string is not valid. */
+type ParseError struct {
+  RepositoryString string
+  Kind             ParseErrorKind
+  // The part of the repository string that is not valid.
+  Value string
+}
+
+func (e *ParseError) Error() string {
+  var reason string
+  switch e.Kind {
+  case UnsupportedProtocol:
+    reason = fmt.Sprintf("unsupported protocol `%s`", e.Value)
+  case UnsupportedHost:
+    reason = fmt.Sprintf("unsupported host `%s`", e.Value)
+  case InvalidOwner:
+    reason = fmt.Sprintf("invalid owner `%s`", e.Value)
+  case InvalidName:
+    reason = fmt.Sprintf("invalid name `%s`", e.Value)
+  case ExtraSegments:
+    reason = fmt.Sprintf("extra path segments `%s`", e.Value)
+  case NotFound:
+    reason = fmt.Sprintf("local path `%s` not found", e.Value)
+  case InvalidRef:
+    reason = fmt.Sprintf("invalid ref `%s`", e.Value)
+  default:
+    reason = `expected [host/]owner/name`
+  }
+  return fmt.Sprintf("repository `%s` not valid: %s", e.RepositoryString, reason)
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import "strings"
+
+/** A short prefix for repository strings, like `gh:` in `gh:coollog/gitcd`. */
+type Prefix struct {
+  // What the prefix stands for, like `https://github.com/` or `git@git.example.com:`.
+  Url string
+  // The owner to use when the repository string after the prefix is just a name. Optional.
+  Owner string
+}
+
+/** Prefixes that are always available, unless the user defines prefixes with the same names. */
+var DefaultPrefixes = map[string]Prefix{
+  `gh`: {Url: `https://github.com/`},
+  `gl`: {Url: `https://gitlab.com/`},
+  `bb`: {Url: `https://bitbucket.org/`},
+}
+
+/** Parses repository strings with the user's configuration. The zero Parser uses no configuration. */
+type Parser struct {
+  // URL rewrites from the git config and the gitcd config.
+  Rewrites Rewrites
+  // Maps from SSH host aliases to their real host names, from the SSH config.
+  SshHosts map[string]string
+  // Maps from prefix names (without the `:`) to user-defined prefixes. These are in addition to the DefaultPrefixes.
+  Prefixes map[string]Prefix
+  // Maps from local paths to the repository prefixes (like `host` or `host/owner`) of the repositories under them.
+  LocalPaths map[string]string
+}
+
+/**
+ * Parses the repositoryString into a Target, like the package-level Parse.
+ *
+ * The Rewrites apply so that the identity of the repository is that of the URL before rewriting. For example, a URL
+ * for a mirror of `https://github.com/` parses to the repository on github.com, and a URL starting with an alias like
+ * `gh:` parses as the URL that the alias is instead of.
+ *
+ * Prefixes expand first. For example, with the prefix `work` as `git@git.example.com:` with owner `platform`,
+ * `work:billing` parses as `git@git.example.com:platform/billing`.
+ */
+func (p *Parser) Parse(repositoryString string) (Target, error) {
+  repositoryString = p.expandPrefix(repositoryString)
+
+  // Undoes rewrites, like for URLs of a mirror.
+  unrewrittenString := p.Rewrites.Unapply(repositoryString)
+  if unrewrittenString != repositoryString {
+    if target, err := p.parseTarget(unrewrittenString); err == nil {
+      return target, nil
+    }
+  }
+
+  target, err := p.parseTarget(repositoryString)
+  if err == nil {
+    return target, nil
+  }
+
+  // Applies rewrites, like for aliases.
+  rewrittenString := p.Rewrites.Apply(repositoryString)
+  if rewrittenString != repositoryString {
+    if target, rewrittenErr := p.parseTarget(rewrittenString); rewrittenErr == nil {
+      return target, nil
+    }
+  }
+  return Target{}, err
+}
+
+/** Canonicalizes the repositoryString into a Repository, like the package-level Canonicalize. */
+func (p *Parser) Canonicalize(repositoryString string) (Repository, error) {
+  target, err := p.Parse(repositoryString)
+  if err != nil {
+    return Repository{}, err
+  }
+  return target.Repository, nil
+}
+
+/** Checks if the host has known naming rules, rather than the Generic rules. */
+func (p *Parser) IsKnownHost(host string) bool {
+  _, ok := findKnownForge(host)
+  return ok
+}
+
+/** Maps the SSH host alias to its real host name. Returns the host itself if it is not an alias. */
+func (p *Parser) sshHost(host string) string {
+  if hostName, ok := p.SshHosts[host]; ok {
+    return hostName
+  }
+  return host
+}
+
+/** Expands the prefix of the repositoryString, if it has one. */
+func (p *Parser) expandPrefix(repositoryString string) string {
+  prefixName, rest := splitPrefix(repositoryString)
+  if len(prefixName) == 0 {
+    return repositoryString
+  }
+
+  prefix, ok := p.Prefixes[prefixName]
+  if !ok {
+    prefix, ok = DefaultPrefixes[prefixName]
+  }
+  if !ok {
+    return repositoryString
+  }
+
+  if len(prefix.Owner) > 0 && !strings.Contains(rest, `/`) {
+    rest = prefix.Owner + `/` + rest
+  }
+  return prefix.Url + rest
+}
+
+/** Splits a repositoryString like `gh:coollog/gitcd` into its prefix name and the rest. */
+func splitPrefix(repositoryString string) (string, string) {
+  parts := strings.SplitN(repositoryString, `:`, 2)
+
+  // URLs like `https://github.com/...` have a protocol rather than a prefix.
+  if len(parts) != 2 || strings.HasPrefix(parts[1], `//`) || strings.ContainsAny(parts[0], `/@.`) {
+    return ``, repositoryString
+  }
+  return parts[0], parts[1]
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "io/ioutil"
+  "os"
+  "os/exec"
+  "path"
+  "strconv"
+  "strings"
+)
+
+// Clones can be partial: shallow (with `--depth`), blobless or treeless (with `--filter`), or sparse (with `--sparse`).
+// Operations on clones check how the clone is partial, since the clone might not have been made by this run. The checks
+// read the files in the .git directory of the clone rather than running git, since the listing checks every clone.
+
+/** Builds the arguments to `git clone` for the options, including the submodule options. */
+func (o CloneOptions) cloneArgs() []string {
+  var args []string
+  if o.Depth > 0 {
+    args = append(args, `--depth`, strconv.Itoa(o.Depth))
+  }
+  if len(o.Filter) > 0 {
+    args = append(args, `--filter=`+o.Filter)
+  }
+  if o.Sparse {
+    args = append(args, `--sparse`)
+  }
+  return append(args, o.submoduleArgs()...)
+}
+
+/** Sets the cone paths of the sparse checkout of the clone at directory, if the options are sparse with paths. */
+func (o CloneOptions) setSparsePaths(directory string) error {
+  if !o.Sparse || len(o.SparsePaths) == 0 {
+    return nil
+  }
+  args := append([]string{"-C", directory, "sparse-checkout", "set", "--cone"}, o.SparsePaths...)
+  return command(exec.Command("git", args...))
+}
+
+/** Checks if the clone at directory is shallow. */
+func IsShallow(directory string) bool {
+  _, err := os.Stat(path.Join(directory, `.git`, `shallow`))
+  return err == nil
+}
+
+/** Checks if the clone at directory is a sparse checkout. */
+func IsSparse(directory string) bool {
+  return gitConfigBool(directory, `core`, `sparsecheckout`)
+}
+
+/** Checks if the clone at directory is missing objects that it fetches on demand, like a blobless clone. */
+func IsPartial(directory string) bool {
+  return gitConfigBool(directory, `remote "origin"`, `promisor`)
+}
+
+/**
+ * Reads the boolean key in the section from the config files of the clone at directory. Sparse checkouts set their
+ * keys in config.worktree, which overrides config. Returns false if the key is not set.
+ */
+func gitConfigBool(directory string, section string, key string) bool {
+  var value string
+  for _, configFile := range []string{`config`, `config.worktree`} {
+    contents, err := ioutil.ReadFile(path.Join(directory, `.git`, configFile))
+    if err != nil {
+      continue
+    }
+
+    inSection := false
+    for _, line := range strings.Split(string(contents), "\n") {
+      line = strings.TrimSpace(line)
+      if strings.HasPrefix(line, `[`) {
+        inSection = strings.EqualFold(strings.TrimSuffix(strings.TrimPrefix(line, `[`), `]`), section)
+        continue
+      }
+      keyValue := strings.SplitN(line, `=`, 2)
+      if !inSection || !strings.EqualFold(strings.TrimSpace(keyValue[0]), key) {
+        continue
+      }
+      // Keys without values are true, and later values override earlier ones.
+      value = `true`
+      if len(keyValue) == 2 {
+        value = strings.ToLower(strings.TrimSpace(keyValue[1]))
+      }
+    }
+  }
+  return value == `true` || value == `yes` || value == `on` || value == `1`
+}
+
+/** Adds the repositoryPath to the sparse checkout of the clone at directory. */
+func AddSparsePath(directory string, repositoryPath string) error {
+  return command(exec.Command("git", "-C", directory, "sparse-checkout", "add", repositoryPath))
+}
+
+/**
+ * Gets the arguments to `git fetch` that keep the clone at directory as partial as it is. Fetching into a shallow clone
+ * without a depth would fetch the whole history of the fetched ref.
+ */
+func fetchArgs(directory string) []string {
+  if IsShallow(directory) {
+    return []string{`--depth`, `1`}
+  }
+  return nil
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import "syscall"
+
+/** The exit code of processes that are still running. */
+const stillActive = 259
+
+/** Checks if the process with the pid is running. */
+func processAlive(pid int) bool {
+  handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+  if err != nil {
+    return false
+  }
+  defer syscall.CloseHandle(handle)
+
+  var exitCode uint32
+  if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+    return false
+  }
+  return exitCode == stillActive
+}
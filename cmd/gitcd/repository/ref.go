@@ -0,0 +1,111 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "errors"
+  "fmt"
+  "os/exec"
+  "strings"
+)
+
+/**
+ * Makes the ref of the target available in the clone at directory and splits the ref from the path.
+ *
+ * Refs can contain slashes, so `tree/feature/x/cmd` could be the ref `feature` with path `x/cmd` or the ref `feature/x`
+ * with path `cmd`. This tries the shortest ref first, fetching it from origin if it is not in the clone already. Refs
+ * that are not valid are skipped without running git, since they come from pasted links.
+ */
+func ResolveRef(directory string, target Target) (Target, error) {
+  refPathParts := strings.Split(strings.Trim(target.Ref+`/`+target.Path, `/`), `/`)
+
+  for i := 1; i <= len(refPathParts); i++ {
+    ref := strings.Join(refPathParts[:i], `/`)
+    if !IsValidRef(ref) {
+      continue
+    }
+    if !hasRef(directory, ref) && !fetchRef(directory, ref) {
+      continue
+    }
+
+    target.Ref = ref
+    target.Path = strings.Join(refPathParts[i:], `/`)
+    return target, nil
+  }
+
+  return target, errors.New(fmt.Sprintf("Could not find ref `%s` in `%s`", target.Ref, directory))
+}
+
+/**
+ * Checks if the ref is a valid ref name, like `git check-ref-format --allow-onelevel` does. Also rejects refs that start
+ * with `-`, so that refs from repository strings cannot pass options to git. Commits are valid refs, since they are hex.
+ */
+func IsValidRef(ref string) bool {
+  if len(ref) == 0 || ref == `@` || strings.HasPrefix(ref, `-`) || strings.HasPrefix(ref, `/`) || strings.HasSuffix(ref, `/`) || strings.HasSuffix(ref, `.`) {
+    return false
+  }
+  if strings.Contains(ref, `..`) || strings.Contains(ref, `//`) || strings.Contains(ref, `@{`) || strings.ContainsAny(ref, " ~^:?*[\\") {
+    return false
+  }
+  for _, character := range ref {
+    if character < 0x20 || character == 0x7f {
+      return false
+    }
+  }
+  for _, component := range strings.Split(ref, `/`) {
+    if strings.HasPrefix(component, `.`) || strings.HasSuffix(component, `.lock`) {
+      return false
+    }
+  }
+  return true
+}
+
+/**
+ * Checks if the clone at directory has the ref checked out, either as its current branch or as the commit at HEAD. Refs
+ * that are only on origin, like `main` for `origin/main`, count if the current branch has the same name.
+ */
+func IsCheckedOut(directory string, ref string) bool {
+  currentBranch, err := exec.Command("git", "-C", directory, "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+  if err == nil && strings.TrimSpace(string(currentBranch)) == ref {
+    return true
+  }
+
+  head, err := exec.Command("git", "-C", directory, "rev-parse", "--verify", "--quiet", `HEAD^{commit}`).Output()
+  if err != nil {
+    return false
+  }
+  commit, err := exec.Command("git", "-C", directory, "rev-parse", "--verify", "--quiet", "--end-of-options", ref+`^{commit}`).Output()
+  return err == nil && string(commit) == string(head)
+}
+
+/** Checks if the ref is a commit in the clone at directory, either locally or on origin. */
+func hasRef(directory string, ref string) bool {
+  for _, commitish := range []string{ref, `origin/` + ref} {
+    err := exec.Command("git", "-C", directory, "rev-parse", "--verify", "--quiet", "--end-of-options", commitish+`^{commit}`).Run()
+    if err == nil {
+      return true
+    }
+  }
+  return false
+}
+
+/** Fetches the ref from origin into the clone at directory. Returns true if successful. */
+func fetchRef(directory string, ref string) bool {
+  args := append([]string{"-C", directory, "fetch", "--quiet"}, fetchArgs(directory)...)
+  cmd := exec.Command("git", append(args, "--end-of-options", "origin", ref)...)
+  return cmd.Run() == nil
+}
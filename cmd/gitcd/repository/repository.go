@@ -21,13 +21,80 @@ import (
     "errors"
   "path"
   "os"
+  "fmt"
+  "strings"
+  "net/url"
 )
 
+/** The host assumed when the repository string does not name one. */
+const DefaultHost = `github.com`
+
 type Repository struct {
+  Host string
+  // The namespace of the repository. Hosts like GitLab allow nested namespaces, like `group/subgroup`.
   Owner string
   Name  string
 }
 
+/**
+ * Gets the directory of the repository relative to the gitcd home.
+ *
+ * Repositories on the DefaultHost and the LocalHost live directly under the gitcd home (as `owner/name`) and
+ * repositories on other hosts live under a directory for their host (as `host/owner/name`).
+ */
+func (r Repository) Path() string {
+  if len(r.Host) == 0 || r.Host == DefaultHost || r.Host == LocalHost {
+    return path.Join(r.Owner, r.Name)
+  }
+  return path.Join(r.Host, r.Owner, r.Name)
+}
+
+/** Gets the clone-able HTTPS URL for the repository. Repositories on the LocalHost have no URL. */
+func (r Repository) Url() string {
+  if r.Host == LocalHost {
+    return ``
+  }
+  return fmt.Sprintf(`https://%s/%s`, r.HostOrDefault(), FindForge(r.HostOrDefault()).formatPath(r.Owner, r.Name))
+}
+
+/**
+ * Gets the identity of the repository. Repositories with the same key are the same repository. Hosts like GitHub
+ * ignore case, so `CoolLog/GitCD` and `coollog/gitcd` have the same key there.
+ */
+func (r Repository) Key() string {
+  key := path.Join(r.HostOrDefault(), r.Owner, r.Name)
+  if FindForge(r.HostOrDefault()).CaseInsensitive {
+    return strings.ToLower(key)
+  }
+  return key
+}
+
+/** Gets the host, defaulting to the DefaultHost. */
+func (r Repository) HostOrDefault() string {
+  if len(r.Host) == 0 {
+    return DefaultHost
+  }
+  return r.Host
+}
+
+/** Converts a directory relative to the gitcd home back into a Repository. This is the inverse of Path. */
+func FromPath(repositoryPath string) (Repository, error) {
+  parts := strings.Split(repositoryPath, `/`)
+
+  // Owners on the DefaultHost cannot contain dots, so a first part with a dot is a host.
+  if strings.Contains(parts[0], `.`) {
+    if len(parts) >= 3 {
+      return Repository{parts[0], strings.Join(parts[1:len(parts)-1], `/`), parts[len(parts)-1]}, nil
+    }
+    if len(parts) == 2 && FindForge(parts[0]).Ownerless {
+      return Repository{parts[0], ``, parts[1]}, nil
+    }
+  } else if len(parts) == 2 {
+    return Repository{DefaultHost, parts[0], parts[1]}, nil
+  }
+  return Repository{}, errors.New(fmt.Sprintf("`%s` is not a repository path", repositoryPath))
+}
+
 /** Repository with a directory path. */
 type ResolvedRepository struct {
   Repository Repository
@@ -39,46 +106,158 @@ func (rr *ResolvedRepository) Exists() bool {
   return !os.IsNotExist(err)
 }
 
-var PrefixProtocol = regexp.MustCompile(`(((git|ssh|http(s)?)://)?github\.com/)`)
-var PrefixGit = regexp.MustCompile(`(git@github\.com:)`)
-var Prefix = regexp.MustCompile(`(` + PrefixProtocol.String() + `|` + PrefixGit.String() + `)?`)
-var RepositoryPart = regexp.MustCompile(`[\w-_]+`)
-var RepositoryRegex = regexp.MustCompile(`^` + Prefix.String() + `(?P<owner>` + RepositoryPart.String() + `)/(?P<name>` + RepositoryPart.String() + `)(\.git)?$`)
+var HostPart = regexp.MustCompile(`^[\w-]+(\.[\w-]+)+$`)
+var ProtocolRegex = regexp.MustCompile(`^(?P<protocol>[\w+.-]+)://`)
+var ScpRegex = regexp.MustCompile(`^(?P<user>[\w.-]+)@(?P<host>[^/:]+):(?P<path>.*)$`)
+var HostPathRegex = regexp.MustCompile(`^(?P<host>[^/]*\.[^/]*)/(?P<path>.*)$`)
+
+/** Protocols that repositories can be cloned with. */
+var SupportedProtocols = map[string]bool{`git`: true, `ssh`: true, `http`: true, `https`: true}
 
 /**
  * Canonicalizes the repositoryString into a Repository.
  *
  * For example:
- *   coollog/gitcd -> (Owner: coollog, Name: gitcd)
- *   github.com/coollog/gitcd -> (Owner: coollog, Name: gitcd)
- *   https://github.com/coollog/gitcd -> (Owner: coollog, Name: gitcd)
+ *   coollog/gitcd -> (Host: github.com, Owner: coollog, Name: gitcd)
+ *   github.com/coollog/gitcd -> (Host: github.com, Owner: coollog, Name: gitcd)
+ *   https://github.com/coollog/gitcd -> (Host: github.com, Owner: coollog, Name: gitcd)
+ *   git@gitlab.com:coollog/gitcd.git -> (Host: gitlab.com, Owner: coollog, Name: gitcd)
+ *   gitlab.com/platform/infra/terraform-modules -> (Host: gitlab.com, Owner: platform/infra, Name: terraform-modules)
+ *   socketio/socket.io.git -> (Host: github.com, Owner: socketio, Name: socket.io)
+ *
+ * The host is lowercase. The owner and name keep their case, but on hosts that ignore case, repository strings that
+ * differ only in case canonicalize to repositories with the same Key.
+ *
+ * Returns a *ParseError if the repositoryString is not valid.
  */
 func Canonicalize(repositoryString string) (Repository, error) {
-  if !RepositoryRegex.MatchString(repositoryString) {
-    return Repository{}, errors.New(`repository not valid`)
+  target, err := Parse(repositoryString)
+  if err != nil {
+    return Repository{}, err
   }
+  return target.Repository, nil
+}
+
+/**
+ * Splits the repositoryString into its host and the path of the repository on that host. Also returns the prefix to
+ * put before the path to make a clone-able URL, or empty if the repositoryString is not a URL.
+ *
+ * SSH host aliases map to their real host, but the prefix keeps the alias so that cloning uses the SSH config for it.
+ */
+func (p *Parser) splitHost(repositoryString string) (string, string, string, error) {
+  var host, repositoryPath, cloneUrlPrefix string
 
-  // Extracts the owner and name from repositoryString.
-  repositoryMatches := matchNamedGroups(repositoryString)
-  return Repository{repositoryMatches[`owner`], repositoryMatches[`name`]}, nil
+  switch {
+  case ProtocolRegex.MatchString(repositoryString):
+    repositoryMatches := matchNamedGroups(ProtocolRegex, repositoryString)
+    if !SupportedProtocols[strings.ToLower(repositoryMatches[`protocol`])] {
+      return ``, ``, ``, &ParseError{repositoryString, UnsupportedProtocol, repositoryMatches[`protocol`]}
+    }
+
+    // Parses the full URL syntax, like `ssh://git@bitbucket.example.com:7999/project/name.git`. The user and port are
+    // part of how to clone the repository, but not part of its identity. The query and fragment are dropped, like in
+    // `https://github.com/owner/name?tab=readme`.
+    repositoryUrl, err := url.Parse(repositoryString)
+    if err != nil {
+      return ``, ``, ``, &ParseError{repositoryString, InvalidFormat, repositoryString}
+    }
+    host, repositoryPath = repositoryUrl.Hostname(), strings.TrimPrefix(repositoryUrl.Path, `/`)
+    cloneUrlPrefix = (&url.URL{Scheme: repositoryUrl.Scheme, User: repositoryUrl.User, Host: repositoryUrl.Host}).String() + `/`
+    if strings.ToLower(repositoryUrl.Scheme) == `ssh` {
+      host = p.sshHost(host)
+    }
+
+  case ScpRegex.MatchString(repositoryString):
+    repositoryMatches := matchNamedGroups(ScpRegex, repositoryString)
+    host, repositoryPath = repositoryMatches[`host`], repositoryMatches[`path`]
+    cloneUrlPrefix = repositoryMatches[`user`] + `@` + host + `:`
+    host = p.sshHost(host)
+
+  case HostPathRegex.MatchString(repositoryString):
+    repositoryMatches := matchNamedGroups(HostPathRegex, repositoryString)
+    host, repositoryPath = repositoryMatches[`host`], repositoryMatches[`path`]
+
+  default:
+    // Defaults to the DefaultHost if the repositoryString does not name a host.
+    host, repositoryPath = DefaultHost, repositoryString
+  }
+
+  if !HostPart.MatchString(host) {
+    return ``, ``, ``, &ParseError{repositoryString, UnsupportedHost, host}
+  }
+  return strings.ToLower(host), repositoryPath, cloneUrlPrefix, nil
 }
 
-/** Resolves the repo directory under the absoluteGitcdHome. */
+/** Parses the repositoryPath into a Repository on host according to the naming rules of the host. */
+func parsePath(repositoryString string, host string, repositoryPath string) (Repository, error) {
+  forge := FindForge(host)
+  if len(forge.Host) > 0 {
+    host = forge.Host
+  }
+
+  if strings.Contains(repositoryPath, `//`) || strings.HasPrefix(repositoryPath, `/`) || strings.HasSuffix(repositoryPath, `/`) {
+    return Repository{}, &ParseError{repositoryString, InvalidFormat, repositoryPath}
+  }
+  repositoryPath = strings.TrimSuffix(repositoryPath, `.git`)
+
+  // Converts the URL shapes of the host, like `org/project/_git/name` on Azure DevOps.
+  if forge.ParsePath != nil {
+    parsedPath, ok := forge.ParsePath(repositoryPath)
+    if !ok {
+      return Repository{}, &ParseError{repositoryString, InvalidFormat, repositoryPath}
+    }
+    repositoryPath = parsedPath
+  }
+
+  parts := strings.Split(repositoryPath, `/`)
+  if forge.Ownerless {
+    if len(parts) > 1 {
+      return Repository{}, &ParseError{repositoryString, ExtraSegments, strings.Join(parts[1:], `/`)}
+    }
+  } else if len(parts) < 2 {
+    return Repository{}, &ParseError{repositoryString, InvalidFormat, repositoryPath}
+  }
+  ownerParts, name := parts[:len(parts)-1], parts[len(parts)-1]
+
+  if !forge.Nested && len(ownerParts) > 1 {
+    return Repository{}, &ParseError{repositoryString, ExtraSegments, strings.Join(parts[2:], `/`)}
+  }
+  for _, ownerPart := range ownerParts {
+    if !forge.OwnerPart.MatchString(ownerPart) {
+      return Repository{}, &ParseError{repositoryString, InvalidOwner, ownerPart}
+    }
+  }
+  if !forge.NamePart.MatchString(name) || name == `.` || name == `..` {
+    return Repository{}, &ParseError{repositoryString, InvalidName, name}
+  }
+
+  return Repository{host, strings.Join(ownerParts, `/`), name}, nil
+}
+
+/**
+ * Resolves the repo directory under the absoluteGitcdHome.
+ *
+ * On hosts that ignore case, an existing clone that differs only in case is the repo directory. The Repository of the
+ * ResolvedRepository then has the case of that clone, which is the case that the repo was first cloned with.
+ */
 func Resolve(gitcdHome string, repo Repository) ResolvedRepository {
+  if FindForge(repo.HostOrDefault()).CaseInsensitive {
+    repo = findClonedCase(gitcdHome, repo)
+  }
   return ResolvedRepository{
     repo,
-    path.Join(gitcdHome, repo.Owner, repo.Name),
+    path.Join(gitcdHome, repo.Path()),
   }
 }
 
 /**
- * Matches the named groups in RepositoryRegex and returns a map from the named groups to their matched values.
+ * Matches the named groups in regex and returns a map from the named groups to their matched values.
  */
-func matchNamedGroups(repositoryString string) map[string]string {
-  matchList := RepositoryRegex.FindStringSubmatch(repositoryString)
+func matchNamedGroups(regex *regexp.Regexp, repositoryString string) map[string]string {
+  matchList := regex.FindStringSubmatch(repositoryString)
 
   matchMap := make(map[string]string)
-  for i, name := range RepositoryRegex.SubexpNames() {
+  for i, name := range regex.SubexpNames() {
     if len(name) > 0 {
       matchMap[name] = matchList[i]
     }
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "bufio"
+  "bytes"
+  "os/exec"
+  "strings"
+)
+
+/**
+ * Rewrites URLs starting with InsteadOf to start with Base instead, like git's `url.<base>.insteadOf`. Push rewrites,
+ * like git's `url.<base>.pushInsteadOf`, only apply to URLs for pushing.
+ */
+type Rewrite struct {
+  Base      string
+  InsteadOf string
+  Push      bool
+}
+
+type Rewrites []Rewrite
+
+/** Rewrites the url for fetching with the rewrite with the longest matching InsteadOf, like git does. */
+func (r Rewrites) Apply(url string) string {
+  return r.apply(url, false)
+}
+
+/** Rewrites the url for pushing. Returns empty if no push rewrite matches. */
+func (r Rewrites) ApplyPush(url string) string {
+  pushUrl := r.apply(url, true)
+  if pushUrl == url {
+    return ``
+  }
+  return pushUrl
+}
+
+func (r Rewrites) apply(url string, push bool) string {
+  var longest *Rewrite
+  for i, rewrite := range r {
+    if rewrite.Push != push || !strings.HasPrefix(url, rewrite.InsteadOf) {
+      continue
+    }
+    if longest == nil || len(rewrite.InsteadOf) > len(longest.InsteadOf) {
+      longest = &r[i]
+    }
+  }
+
+  if longest == nil {
+    return url
+  }
+  return longest.Base + strings.TrimPrefix(url, longest.InsteadOf)
+}
+
+/**
+ * Undoes the rewrite with the longest matching Base on the url. For example, if `https://mirror.example.com/` is
+ * instead of `https://github.com/`, then `https://mirror.example.com/coollog/gitcd` unapplies to
+ * `https://github.com/coollog/gitcd`.
+ */
+func (r Rewrites) Unapply(url string) string {
+  var longest *Rewrite
+  for i, rewrite := range r {
+    if len(rewrite.Base) == 0 || !strings.HasPrefix(url, rewrite.Base) {
+      continue
+    }
+    if longest == nil || len(rewrite.Base) > len(longest.Base) {
+      longest = &r[i]
+    }
+  }
+
+  if longest == nil {
+    return url
+  }
+  return longest.InsteadOf + strings.TrimPrefix(url, longest.Base)
+}
+
+/** Reads the `url.<base>.insteadOf` and `url.<base>.pushInsteadOf` rewrites from the git config. */
+func GitRewrites() (Rewrites, error) {
+  output, err := exec.Command("git", "config", "--get-regexp", `^url\..*\.(push)?insteadof$`).Output()
+  if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+    // No rewrites are configured.
+    return nil, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  var rewrites Rewrites
+  scanner := bufio.NewScanner(bytes.NewReader(output))
+  for scanner.Scan() {
+    // Each line is like `url.<base>.insteadof <instead of>`.
+    keyValue := strings.SplitN(scanner.Text(), ` `, 2)
+    if len(keyValue) != 2 {
+      continue
+    }
+    key := strings.TrimPrefix(keyValue[0], `url.`)
+
+    switch {
+    case strings.HasSuffix(key, `.pushinsteadof`):
+      rewrites = append(rewrites, Rewrite{strings.TrimSuffix(key, `.pushinsteadof`), keyValue[1], true})
+    case strings.HasSuffix(key, `.insteadof`):
+      rewrites = append(rewrites, Rewrite{strings.TrimSuffix(key, `.insteadof`), keyValue[1], false})
+    }
+  }
+  return rewrites, scanner.Err()
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/mitchellh/go-homedir"
+)
+
+/** How deep Includes can nest, like in ssh. This also stops files that include themselves. */
+const maxSshIncludeDepth = 16
+
+/**
+ * Loads the SSH host aliases from the sshConfigFile (usually `~/.ssh/config`). Returns a map from each `Host` alias to
+ * its `HostName`. Only literal aliases are mapped, since patterns like `*.example.com` are not aliases to type.
+ *
+ * For example, this maps `github-work` to `github.com`:
+ *
+ *   Host github-work
+ *     HostName github.com
+ *     IdentityFile ~/.ssh/id_work
+ */
+func LoadSshHosts(sshConfigFile string) (map[string]string, error) {
+  sshHosts := make(map[string]string)
+  err := loadSshHosts(sshConfigFile, sshHosts, 0)
+  if os.IsNotExist(err) {
+    return sshHosts, nil
+  }
+  return sshHosts, err
+}
+
+func loadSshHosts(sshConfigFile string, sshHosts map[string]string, depth int) error {
+  if depth > maxSshIncludeDepth {
+    return errors.New(fmt.Sprintf("Includes in `%s` nest too deeply", sshConfigFile))
+  }
+
+  file, err := os.Open(sshConfigFile)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+
+  var aliases []string
+  scanner := bufio.NewScanner(file)
+  for scanner.Scan() {
+    keyword, arguments := splitSshConfigLine(scanner.Text())
+
+    switch strings.ToLower(keyword) {
+    case `host`:
+      aliases = nil
+      for _, alias := range arguments {
+        if !strings.ContainsAny(alias, `*?!`) {
+          aliases = append(aliases, alias)
+        }
+      }
+
+    case `match`:
+      aliases = nil
+
+    case `hostname`:
+      if len(arguments) == 0 {
+        continue
+      }
+      for _, alias := range aliases {
+        // The first HostName for an alias wins, like in ssh.
+        if _, ok := sshHosts[alias]; !ok {
+          sshHosts[alias] = strings.ToLower(strings.Replace(arguments[0], `%h`, alias, -1))
+        }
+      }
+
+    case `include`:
+      // Included files are relative to ~/.ssh, which is where the sshConfigFile usually is.
+      for _, include := range arguments {
+        include, err := homedir.Expand(include)
+        if err != nil {
+          return err
+        }
+        if !filepath.IsAbs(include) {
+          include = filepath.Join(filepath.Dir(sshConfigFile), include)
+        }
+        includeFiles, _ := filepath.Glob(include)
+        for _, includeFile := range includeFiles {
+          if err := loadSshHosts(includeFile, sshHosts, depth+1); err != nil && !os.IsNotExist(err) {
+            return err
+          }
+        }
+      }
+    }
+  }
+  return scanner.Err()
+}
+
+/** Splits an SSH config line into its keyword and arguments. Keywords are separated by whitespace or `=`. */
+func splitSshConfigLine(line string) (string, []string) {
+  line = strings.TrimSpace(line)
+  if len(line) == 0 || strings.HasPrefix(line, `#`) {
+    return ``, nil
+  }
+
+  keywordEnd := strings.IndexAny(line, " \t=")
+  if keywordEnd < 0 {
+    return line, nil
+  }
+  keyword := line[:keywordEnd]
+  arguments := strings.Fields(strings.TrimLeft(line[keywordEnd:], " \t="))
+  for i, argument := range arguments {
+    arguments[i] = strings.Trim(argument, `"`)
+  }
+  return keyword, arguments
+}
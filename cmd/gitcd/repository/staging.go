@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "fmt"
+  "io/ioutil"
+  "os"
+  "os/signal"
+  "path"
+  "strconv"
+  "strings"
+  "syscall"
+)
+
+/**
+ * The directory under the gitcd home that clones are staged in until they complete. Each clone stages in its own
+ * directory named `<pid>-<random>`, so that stale staging directories from crashed runs can be found by their pid.
+ */
+const StagingDirectoryName = `.gitcd-staging`
+
+/** Makes a new staging directory for the current process under the gitcdHome. */
+func newStagingDirectory(gitcdHome string) (string, error) {
+  stagingRoot := path.Join(gitcdHome, StagingDirectoryName)
+  if err := os.MkdirAll(stagingRoot, 0755); err != nil {
+    return ``, err
+  }
+  return ioutil.TempDir(stagingRoot, fmt.Sprintf(`%d-`, os.Getpid()))
+}
+
+/** Removes the staging directories under the gitcdHome whose processes are no longer running. */
+func RemoveStaleStagingDirectories(gitcdHome string) error {
+  stagingRoot := path.Join(gitcdHome, StagingDirectoryName)
+  fileInfos, err := ioutil.ReadDir(stagingRoot)
+  if os.IsNotExist(err) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+
+  for _, fileInfo := range fileInfos {
+    pid, err := strconv.Atoi(strings.SplitN(fileInfo.Name(), `-`, 2)[0])
+    if err == nil && processAlive(pid) {
+      continue
+    }
+    if err := os.RemoveAll(path.Join(stagingRoot, fileInfo.Name())); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+/**
+ * Removes the stagingDirectory and exits if the process is interrupted, like with Ctrl-C. git gets the interrupt too,
+ * so it stops cloning. Returns a function that stops watching for interrupts.
+ */
+func removeOnInterrupt(stagingDirectory string) func() {
+  signals := make(chan os.Signal, 1)
+  done := make(chan struct{})
+  signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+  go func() {
+    select {
+    case <-signals:
+      os.RemoveAll(stagingDirectory)
+      os.Exit(1)
+    case <-done:
+    }
+  }()
+
+  return func() {
+    signal.Stop(signals)
+    close(done)
+  }
+}
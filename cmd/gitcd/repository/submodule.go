@@ -0,0 +1,130 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "os"
+  "os/exec"
+  "path"
+  "strconv"
+  "strings"
+)
+
+/** The states of submodules, from the first character of `git submodule status`. */
+type SubmoduleState int
+
+const (
+  // The submodule is checked out at the commit that the superproject records.
+  SubmoduleInSync SubmoduleState = iota
+  // The submodule is not initialized, so its directory is empty.
+  SubmoduleUninitialized
+  // The submodule is checked out at a different commit than the superproject records.
+  SubmoduleOutOfSync
+  // The submodule has merge conflicts.
+  SubmoduleConflicted
+)
+
+func (s SubmoduleState) String() string {
+  switch s {
+  case SubmoduleUninitialized:
+    return `not initialized`
+  case SubmoduleOutOfSync:
+    return `out of sync`
+  case SubmoduleConflicted:
+    return `conflicted`
+  }
+  return `in sync`
+}
+
+/** A submodule and its state. */
+type Submodule struct {
+  Path  string
+  State SubmoduleState
+}
+
+/** Builds the arguments to `git clone` for the submodule options. */
+func (o CloneOptions) submoduleArgs() []string {
+  if !o.Submodules {
+    return nil
+  }
+  args := []string{`--recurse-submodules`}
+  if o.SubmoduleJobs > 0 {
+    args = append(args, `--jobs`, strconv.Itoa(o.SubmoduleJobs))
+  }
+  if o.ShallowSubmodules {
+    args = append(args, `--shallow-submodules`)
+  }
+  return args
+}
+
+/** Checks if the clone at directory has submodules. */
+func HasSubmodules(directory string) bool {
+  _, err := os.Stat(path.Join(directory, `.gitmodules`))
+  return err == nil
+}
+
+/** Gets the submodules of the clone at directory that are not in sync with the superproject, recursively. */
+func FindUnsyncedSubmodules(directory string) ([]Submodule, error) {
+  if !HasSubmodules(directory) {
+    return nil, nil
+  }
+
+  output, err := exec.Command("git", "-C", directory, "submodule", "status", "--recursive").Output()
+  if err != nil {
+    return nil, err
+  }
+
+  var submodules []Submodule
+  for _, line := range strings.Split(string(output), "\n") {
+    // Lines are like `+<sha> <path> (<describe>)`.
+    if len(line) == 0 {
+      continue
+    }
+    fields := strings.Fields(line[1:])
+    if len(fields) < 2 {
+      continue
+    }
+    var state SubmoduleState
+    switch line[0] {
+    case '-':
+      state = SubmoduleUninitialized
+    case '+':
+      state = SubmoduleOutOfSync
+    case 'U':
+      state = SubmoduleConflicted
+    default:
+      continue
+    }
+    submodules = append(submodules, Submodule{fields[1], state})
+  }
+  return submodules, nil
+}
+
+/**
+ * Initializes and updates the submodules of the worktree at worktreeDirectory if the main clone at directory has
+ * initialized submodules, since new worktrees start without them.
+ */
+func updateWorktreeSubmodules(directory string, worktreeDirectory string) error {
+  if !HasSubmodules(worktreeDirectory) {
+    return nil
+  }
+  // Initialized submodules have their URL in the config of the main clone.
+  if err := exec.Command("git", "-C", directory, "config", "--get-regexp", `^submodule\..*\.url$`).Run(); err != nil {
+    return nil
+  }
+  return command(exec.Command("git", "-C", worktreeDirectory, "submodule", "update", "--init", "--recursive"))
+}
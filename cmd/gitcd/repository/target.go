@@ -0,0 +1,159 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "regexp"
+  "strings"
+  "strconv"
+)
+
+/** A parsed repository string: the repository, how to clone it, and where to go within it. */
+type Target struct {
+  Repository Repository
+  // The URL to clone the repository with, or empty if the repository string was not a clone-able URL.
+  CloneUrl string
+  // The ref and the path within the repository from a web URL, like `https://github.com/owner/name/tree/ref/path`.
+  Ref  string
+  Path string
+  // Whether the Path is a file rather than a directory, like for `https://github.com/owner/name/blob/ref/file.go`.
+  IsFile bool
+  // The pull request number, like for `https://github.com/owner/name/pull/123` or `owner/name#123`, or 0 if none.
+  PullRequest int
+  // The branch, tag, or commit to check out in its own worktree, like for `owner/name@release-1.4`, or empty if none.
+  WorktreeRef string
+}
+
+/**
+ * Matches where the repository path ends in web URLs for trees and blobs, like `/tree/` in `owner/name/tree/ref/path` on
+ * GitHub and `/-/blob/` in `group/name/-/blob/ref/file` on GitLab.
+ */
+var WebPathSeparatorRegex = regexp.MustCompile(`(/-)?/(tree|blob)/`)
+
+/** Matches the repository path of pull request web URLs, like `owner/name/pull/123/files`. */
+var PullPathRegex = regexp.MustCompile(`^(?P<repository>.+?)/pull/(?P<number>\d+)(/.*)?$`)
+
+/** Matches the pull request shorthand, like `owner/name#123`. */
+var PullShorthandRegex = regexp.MustCompile(`^(?P<repository>[^#]+)#(?P<number>\d+)$`)
+
+/** Matches the repository path with a ref to check out in a worktree, like `owner/name@v2.0.0` or `owner/name@feature/x`. */
+var WorktreeRefRegex = regexp.MustCompile(`^(?P<repository>[^@]+)@(?P<ref>[^@]+)$`)
+
+/**
+ * Parses the repositoryString into a Target.
+ *
+ * For example:
+ *   https://github.com/coollog/gitcd -> (Repository: github.com/coollog/gitcd, CloneUrl: https://github.com/coollog/gitcd)
+ *   https://github.com/coollog/gitcd/tree/master/cmd -> (Repository: github.com/coollog/gitcd, Ref: master, Path: cmd)
+ *   https://github.com/coollog/gitcd/pull/12 -> (Repository: github.com/coollog/gitcd, PullRequest: 12)
+ *   coollog/gitcd#12 -> (Repository: github.com/coollog/gitcd, PullRequest: 12)
+ *   coollog/gitcd@v1.0 -> (Repository: github.com/coollog/gitcd, WorktreeRef: v1.0)
+ *   coollog/gitcd -> (Repository: github.com/coollog/gitcd)
+ *
+ * Web URLs do not separate the ref from the path, so the Ref is only the first part until ResolveRef checks it against
+ * the clone. Returns a *ParseError if the repositoryString is not valid.
+ */
+func Parse(repositoryString string) (Target, error) {
+  return (&Parser{}).Parse(repositoryString)
+}
+
+/** Parses the repositoryString into a Target without applying rewrites. */
+func (p *Parser) parseTarget(repositoryString string) (Target, error) {
+  if IsLocalPath(repositoryString) {
+    return p.parseLocal(repositoryString)
+  }
+
+  host, repositoryPath, cloneUrlPrefix, err := p.splitHost(repositoryString)
+  if err != nil {
+    return Target{}, err
+  }
+
+  var target Target
+
+  // Splits off the ref and path of web URLs.
+  if webRepositoryPath, kind, refPath := splitWebPath(repositoryString, host, repositoryPath); len(kind) > 0 {
+    repositoryPath = webRepositoryPath
+
+    // Drops line anchors, like in `blob/ref/file.go#L42`.
+    refPath = strings.SplitN(refPath, `#`, 2)[0]
+    refPathParts := strings.SplitN(strings.Trim(refPath, `/`), `/`, 2)
+    target.Ref = refPathParts[0]
+    if len(refPathParts) > 1 {
+      target.Path = refPathParts[1]
+    }
+    target.IsFile = kind == `blob`
+    if !IsValidRef(target.Ref) {
+      return Target{}, &ParseError{repositoryString, InvalidRef, target.Ref}
+    }
+  }
+
+  // Splits off the pull request number.
+  for _, pullRegex := range []*regexp.Regexp{PullPathRegex, PullShorthandRegex} {
+    if pullRegex.MatchString(repositoryPath) {
+      pullMatches := matchNamedGroups(pullRegex, repositoryPath)
+      repositoryPath = pullMatches[`repository`]
+      target.PullRequest, _ = strconv.Atoi(pullMatches[`number`])
+    }
+  }
+
+  // Splits off the ref to check out in a worktree.
+  if WorktreeRefRegex.MatchString(repositoryPath) {
+    worktreeRefMatches := matchNamedGroups(WorktreeRefRegex, repositoryPath)
+    repositoryPath = worktreeRefMatches[`repository`]
+    target.WorktreeRef = worktreeRefMatches[`ref`]
+    if !IsValidRef(target.WorktreeRef) {
+      return Target{}, &ParseError{repositoryString, InvalidRef, target.WorktreeRef}
+    }
+  }
+
+  target.Repository, err = parsePath(repositoryString, host, repositoryPath)
+  if err != nil {
+    return Target{}, err
+  }
+
+  if len(cloneUrlPrefix) > 0 {
+    target.CloneUrl = cloneUrlPrefix + repositoryPath
+
+    // Web URLs on hosts with their own URL shapes, like `projects/PROJ/repos/name/browse` on Bitbucket Server, do not
+    // clone, so HTTP URLs clone with the shape of the host instead.
+    forge := FindForge(target.Repository.Host)
+    if forge.FormatPath != nil && strings.HasPrefix(cloneUrlPrefix, `http`) {
+      target.CloneUrl = cloneUrlPrefix + forge.FormatPath(target.Repository.Owner, target.Repository.Name)
+    }
+  }
+
+  return target, nil
+}
+
+/**
+ * Splits the repositoryPath of web URLs for trees and blobs at the first separator that follows a repository, since
+ * nested groups can be named `tree`, like in `group/tree/name`. Returns the repository path, the kind (`tree` or
+ * `blob`), and the ref and path after the separator, or empty strings if the repositoryPath is not a web path.
+ */
+func splitWebPath(repositoryString string, host string, repositoryPath string) (string, string, string) {
+  for _, indexes := range WebPathSeparatorRegex.FindAllStringSubmatchIndex(repositoryPath, -1) {
+    webRepositoryPath, refPath := repositoryPath[:indexes[0]], repositoryPath[indexes[1]:]
+    if len(refPath) == 0 {
+      continue
+    }
+    if _, err := parsePath(repositoryString, host, webRepositoryPath); err != nil {
+      continue
+    }
+    return webRepositoryPath, repositoryPath[indexes[4]:indexes[5]], refPath
+  }
+  return ``, ``, ``
+}
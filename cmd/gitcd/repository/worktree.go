@@ -0,0 +1,220 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package repository
+
+import (
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "log"
+  "os"
+  "os/exec"
+  "path"
+  "path/filepath"
+  "regexp"
+  "strings"
+)
+
+/** Matches the directories of pull request worktrees, like `name@pr-12`. */
+var PullRequestWorktreeRegex = regexp.MustCompile(`@pr-\d+$`)
+
+/**
+ * Gets the directory for the worktree of the resolvedRepository named name. Worktrees live next to the main clone, like
+ * `name@release-1.4`. Slashes in the name become dashes, so `feature/x` is `name@feature-x`.
+ */
+func WorktreeDirectory(resolvedRepository ResolvedRepository, name string) string {
+  return resolvedRepository.Directory + `@` + strings.Replace(name, `/`, `-`, -1)
+}
+
+/** Lists the names of the worktrees next to the main clone of the resolvedRepository, like `pr-12` and `v2.0.0`. */
+func ListWorktrees(resolvedRepository ResolvedRepository) []string {
+  // Clones without worktrees have no .git/worktrees directory, which is quicker to check than the whole owner directory.
+  if _, err := os.Stat(path.Join(resolvedRepository.Directory, `.git`, `worktrees`)); err != nil {
+    return nil
+  }
+
+  fileInfos, err := ioutil.ReadDir(path.Dir(resolvedRepository.Directory))
+  if err != nil {
+    return nil
+  }
+
+  prefix := path.Base(resolvedRepository.Directory) + `@`
+  var names []string
+  for _, fileInfo := range fileInfos {
+    if !fileInfo.IsDir() || !strings.HasPrefix(fileInfo.Name(), prefix) {
+      continue
+    }
+    // Worktrees have a .git file rather than a .git directory.
+    worktreeDirectory := path.Join(path.Dir(resolvedRepository.Directory), fileInfo.Name())
+    if gitFileInfo, err := os.Stat(path.Join(worktreeDirectory, `.git`)); err == nil && !gitFileInfo.IsDir() {
+      names = append(names, strings.TrimPrefix(fileInfo.Name(), prefix))
+    }
+  }
+  return names
+}
+
+/**
+ * Finds the clone under the gitcdHome that the directory is in, either in the clone itself or in one of its worktrees.
+ * Also returns the top directory of the checkout that the directory is in.
+ */
+func FindClone(gitcdHome string, directory string) (ResolvedRepository, string, error) {
+  output, err := exec.Command("git", "-C", directory, "rev-parse", "--show-toplevel", "--git-common-dir").Output()
+  lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+  if err != nil || len(lines) != 2 {
+    return ResolvedRepository{}, ``, errors.New(fmt.Sprintf("`%s` is not in a clone", directory))
+  }
+  checkoutDirectory, gitDirectory := lines[0], lines[1]
+
+  // Worktrees share the git directory of the main clone. The git directory of the main clone is relative to directory.
+  if !filepath.IsAbs(gitDirectory) {
+    gitDirectory = filepath.Join(directory, gitDirectory)
+  }
+  absoluteGitDirectory, err := filepath.Abs(gitDirectory)
+  if err != nil {
+    return ResolvedRepository{}, ``, err
+  }
+  cloneDirectory, err := filepath.EvalSymlinks(filepath.Dir(absoluteGitDirectory))
+  if err != nil {
+    return ResolvedRepository{}, ``, err
+  }
+  realGitcdHome, err := filepath.EvalSymlinks(gitcdHome)
+  if err != nil {
+    return ResolvedRepository{}, ``, err
+  }
+
+  repositoryPath, err := filepath.Rel(realGitcdHome, cloneDirectory)
+  if err != nil || strings.HasPrefix(repositoryPath, `..`) {
+    return ResolvedRepository{}, ``, errors.New(fmt.Sprintf("`%s` is not in a clone under `%s`", directory, gitcdHome))
+  }
+  repo, err := FromPath(filepath.ToSlash(repositoryPath))
+  if err != nil {
+    return ResolvedRepository{}, ``, err
+  }
+  return Resolve(gitcdHome, repo), checkoutDirectory, nil
+}
+
+/**
+ * Checks out the ref (a branch, tag, or commit) in its own worktree, fetching it from origin if it is not in the clone
+ * already. Branches that only exist on origin get a local branch that tracks them, and tags and commits are checked
+ * out detached. Reuses the worktree if it already exists. Returns the worktree directory, or the directory of the main
+ * clone if the ref is the branch that it has checked out.
+ *
+ * New worktrees get submodules like the main clone has.
+ */
+func CheckoutWorktree(resolvedRepository ResolvedRepository, ref string) (string, error) {
+  if !IsValidRef(ref) {
+    return ``, errors.New(fmt.Sprintf("`%s` is not a valid ref", ref))
+  }
+  worktreeDirectory := WorktreeDirectory(resolvedRepository, ref)
+  if _, err := os.Stat(worktreeDirectory); err == nil {
+    return worktreeDirectory, nil
+  }
+
+  // A branch can only be checked out in one worktree.
+  currentBranch, err := exec.Command("git", "-C", resolvedRepository.Directory, "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+  if err == nil && strings.TrimSpace(string(currentBranch)) == ref {
+    return resolvedRepository.Directory, nil
+  }
+
+  // Refs that fetching does not store, like tags and commits fetched by name, are in FETCH_HEAD.
+  commitish := ref
+  if !hasRef(resolvedRepository.Directory, ref) {
+    if !fetchRef(resolvedRepository.Directory, ref) {
+      return ``, errors.New(fmt.Sprintf("Could not find ref `%s` in `%s`", ref, resolvedRepository.Directory))
+    }
+    if !hasRef(resolvedRepository.Directory, ref) {
+      commitish = `FETCH_HEAD`
+    }
+  }
+
+  err = addWorktree(resolvedRepository.Directory, worktreeDirectory, commitish)
+  if err != nil {
+    return ``, err
+  }
+  if err := updateWorktreeSubmodules(resolvedRepository.Directory, worktreeDirectory); err != nil {
+    log.Printf("Could not update the submodules of `%s`: %s\n", worktreeDirectory, err.Error())
+  }
+  return worktreeDirectory, nil
+}
+
+/**
+ * Fetches the pull request into the local branch `pr/<number>` and checks it out in its own worktree, so that the main
+ * clone is untouched. Reuses the worktree if it already exists, fast-forwarding it to the latest commits of the pull
+ * request if update. Returns the worktree directory.
+ *
+ * Pull requests are on the upstream of forks, so clones of forks fetch them from the upstream remote.
+ */
+func CheckoutPullRequest(resolvedRepository ResolvedRepository, number int, update bool) (string, error) {
+  worktreeDirectory := WorktreeDirectory(resolvedRepository, fmt.Sprintf("pr-%d", number))
+  pullRef := fmt.Sprintf("refs/pull/%d/head", number)
+  remote := `origin`
+  if HasUpstream(resolvedRepository.Directory) {
+    remote = UpstreamRemote
+  }
+  args := append([]string{"fetch"}, fetchArgs(resolvedRepository.Directory)...)
+
+  // Pull requests that were force-pushed do not fast-forward, so those stay at what was checked out before. Updating
+  // prints to stderr, since gcd reads the directory to go to from stdout.
+  if _, err := os.Stat(worktreeDirectory); err == nil {
+    if !update {
+      return worktreeDirectory, nil
+    }
+    err := stderrCommand(exec.Command("git", append(append([]string{"-C", worktreeDirectory}, args...), "--quiet", remote, pullRef)...))
+    if err == nil {
+      err = stderrCommand(exec.Command("git", "-C", worktreeDirectory, "merge", "--ff-only", "--quiet", "FETCH_HEAD"))
+    }
+    if err != nil {
+      log.Printf("Could not update `%s` to the latest of pull request %d: %s\n", worktreeDirectory, number, err.Error())
+    }
+    return worktreeDirectory, nil
+  }
+
+  branch := fmt.Sprintf("pr/%d", number)
+  refspec := fmt.Sprintf("+%s:refs/heads/%s", pullRef, branch)
+  err := command(exec.Command("git", append(append([]string{"-C", resolvedRepository.Directory}, args...), remote, refspec)...))
+  if err != nil {
+    return ``, err
+  }
+
+  err = addWorktree(resolvedRepository.Directory, worktreeDirectory, branch)
+  if err != nil {
+    return ``, err
+  }
+  if err := updateWorktreeSubmodules(resolvedRepository.Directory, worktreeDirectory); err != nil {
+    log.Printf("Could not update the submodules of `%s`: %s\n", worktreeDirectory, err.Error())
+  }
+  return worktreeDirectory, nil
+}
+
+/**
+ * Checks if the directory is the worktree of a pull request, like `name@pr-12`. Pull requests can come from anyone, so
+ * their checkouts are not trusted like the clone is.
+ */
+func IsPullRequestWorktree(directory string) bool {
+  return PullRequestWorktreeRegex.MatchString(filepath.Base(directory))
+}
+
+/**
+ * Adds a worktree at worktreeDirectory for the clone at directory with the commitish checked out. Partial clones download
+ * the files of the commitish that they are missing, so this says so first.
+ */
+func addWorktree(directory string, worktreeDirectory string, commitish string) error {
+  if IsPartial(directory) {
+    log.Printf("`%s` is a partial clone, so checking out `%s` downloads its missing files from origin\n", directory, commitish)
+  }
+  return command(exec.Command("git", "-C", directory, "worktree", "add", "--end-of-options", worktreeDirectory, commitish))
+}
